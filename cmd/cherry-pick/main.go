@@ -2,83 +2,417 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v66/github"
 	"github.com/vdemeester/workflows-experiments/internal/cherrypick"
 )
 
+// Exit codes, one per failure class so that workflows can tell them apart.
+// 2 is left to the flag package for usage errors.
+const (
+	exitFailure          = 1
+	exitNotMerged        = 3
+	exitBranchNotFound   = 4
+	exitConflict         = 5
+	exitPushRejected     = 6
+	exitPRCreationFailed = 7
+	exitBranchNotAllowed = 8
+)
+
 func main() {
-	if err := run(); err != nil {
-		log.Fatal(err)
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		if err := validateConfig(os.Args[2:]); err != nil {
+			log.Print(err)
+			os.Exit(exitFailure)
+		}
+		return
+	}
+
+	// on-merge runs the cherry-picks recorded on a PR while it was open,
+	// from the pull_request_target closed event
+	args, onMerge := os.Args[1:], false
+	if len(args) > 0 && args[0] == "on-merge" {
+		args, onMerge = args[1:], true
+	}
+
+	if err := run(args, onMerge); err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
 	}
 }
 
-func run() error {
-	cfg, commentID := parseFlags()
+// exitCode maps an error returned by run to the exit code of its class.
+// When branches failed for different reasons, the first class in this list
+// wins.
+func exitCode(err error) int {
+	classes := []struct {
+		err  error
+		code int
+	}{
+		{cherrypick.ErrNotMerged, exitNotMerged},
+		{cherrypick.ErrBranchNotFound, exitBranchNotFound},
+		{cherrypick.ErrBranchNotAllowed, exitBranchNotAllowed},
+		{cherrypick.ErrConflict, exitConflict},
+		{cherrypick.ErrPushRejected, exitPushRejected},
+		{cherrypick.ErrPRCreationFailed, exitPRCreationFailed},
+	}
+	for _, class := range classes {
+		if errors.Is(err, class.err) {
+			return class.code
+		}
+	}
+	return exitFailure
+}
+
+// validateConfig checks a repository configuration file, the one of the
+// current directory by default
+func validateConfig(args []string) error {
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate-config [file]\n\nValidates a cherry-pick configuration file (default %s).\n", os.Args[0], cherrypick.RepoConfigPath)
+	}
+	_ = flags.Parse(args)
+
+	path := cherrypick.RepoConfigPath
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := cherrypick.ParseRepoConfig(data); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	fmt.Printf("✅ %s is valid\n", path)
+	return nil
+}
+
+func run(args []string, onMerge bool) (err error) {
+	cfg, commentID, set := parseFlags(args)
 
 	ctx := context.Background()
 	client := github.NewClient(nil).WithAuthToken(cfg.Token)
+	githubClient := cherrypick.NewDefaultGitHubClient(client)
 
 	// Create comment poster
-	poster := cherrypick.NewCommentPoster(client, cfg.RepoOwner, cfg.RepoName, cfg.IssueNumber)
+	poster := cherrypick.NewCommentPoster(githubClient, cfg.RepoOwner, cfg.RepoName, cfg.IssueNumber)
 
-	// Add reaction to trigger comment
-	if err := poster.AddReaction(ctx, commentID, "+1"); err != nil {
-		log.Printf("Warning: %v", err)
+	// Acknowledge the trigger comment before any work that can fail, then
+	// replace the acknowledgement with the outcome once done
+	ack, reactErr := poster.AddReaction(ctx, commentID, "eyes")
+	if reactErr != nil {
+		log.Printf("Warning: %v", reactErr)
+	}
+	defer func() {
+		outcome := "rocket"
+		if err != nil {
+			outcome = "confused"
+		}
+		if _, reactErr := poster.AddReaction(ctx, commentID, outcome); reactErr != nil {
+			log.Printf("Warning: %v", reactErr)
+		}
+		if reactErr := poster.RemoveReaction(ctx, commentID, ack); reactErr != nil {
+			log.Printf("Warning: %v", reactErr)
+		}
+	}()
+
+	// The repository configuration provides the options not given on the
+	// command line
+	repoCfg, err := cherrypick.LoadRepoConfig(ctx, githubClient, cfg.RepoOwner, cfg.RepoName)
+	if err != nil {
+		if postErr := poster.PostError(ctx, err.Error()); postErr != nil {
+			log.Printf("Failed to post error comment: %v", postErr)
+		}
+		return err
+	}
+	if repoCfg != nil {
+		log.Printf("Using %s", cherrypick.RepoConfigPath)
+		applyRepoConfig(&cfg, repoCfg, set)
 	}
 
+	// Create service with real implementations, reporting progress on the
+	// summary comment
+	opts := []cherrypick.Option{cherrypick.WithProgressReporter(poster)}
+	signer, err := loadSigner(ctx, &cfg)
+	if err != nil {
+		if postErr := poster.PostError(ctx, err.Error()); postErr != nil {
+			log.Printf("Failed to post error comment: %v", postErr)
+		}
+		return err
+	}
+	if signer != nil {
+		defer signer.Close()
+		opts = append(opts, cherrypick.WithSigner(signer))
+	}
+	switch cfg.Backend {
+	case "git":
+	case "api":
+		// Picks the API cannot apply still need the local clone
+		opts = append(opts, cherrypick.WithGitDataClient(githubClient))
+	default:
+		err = fmt.Errorf("unknown backend %q: expected git or api", cfg.Backend)
+		if postErr := poster.PostError(ctx, err.Error()); postErr != nil {
+			log.Printf("Failed to post error comment: %v", postErr)
+		}
+		return err
+	}
+
+	gitRunner := &cherrypick.CommandGitRunner{Timeout: cfg.GitTimeout}
+	service := cherrypick.NewService(githubClient, gitRunner, opts...)
+
+	if onMerge {
+		return cherryPickDeferred(ctx, &cfg, poster, service)
+	}
+	return cherryPick(ctx, &cfg, poster, service)
+}
+
+// cherryPickDeferred runs the cherry-picks recorded on the PR while it was
+// open, once it is merged
+func cherryPickDeferred(ctx context.Context, cfg *cliConfig, notifier cherrypick.Notifier, service *cherrypick.Service) error {
+	branches, err := service.DeferredBranches(ctx, &cfg.Config)
+	if err != nil {
+		return err
+	}
+	if len(branches) == 0 {
+		log.Printf("No cherry-pick recorded on merged PR #%d", cfg.PRNumber)
+		return nil
+	}
+
+	log.Printf("Running the cherry-picks recorded on PR #%d: %s", cfg.PRNumber, strings.Join(branches, ", "))
+	cfg.Branches = branches
+	return cherryPick(ctx, cfg, notifier, service)
+}
+
+// cherryPick runs the command, reporting its lifecycle through notifier
+func cherryPick(ctx context.Context, cfg *cliConfig, notifier cherrypick.Notifier, service *cherrypick.Service) error {
 	// Validate configuration
 	if err := cherrypick.ValidateConfig(&cfg.Config); err != nil {
-		if postErr := poster.PostError(ctx, err.Error()); postErr != nil {
+		if postErr := notifier.PostError(ctx, err.Error()); postErr != nil {
 			log.Printf("Failed to post error comment: %v", postErr)
 		}
 		return err
 	}
 
-	// Create service with real implementations
-	githubClient := cherrypick.NewDefaultGitHubClient(client)
-	gitRunner := &cherrypick.CommandGitRunner{}
-	service := cherrypick.NewService(githubClient, gitRunner)
+	// Expand the globs and aliases of the target branches
+	branches, err := service.ResolveBranches(ctx, &cfg.Config)
+	if err != nil {
+		if postErr := notifier.PostError(ctx, err.Error()); postErr != nil {
+			log.Printf("Failed to post error comment: %v", postErr)
+		}
+		return err
+	}
+	cfg.Branches = branches
+
+	// Cherry-picks requested on an open PR wait for it to be merged
+	deferred, err := service.Defer(ctx, &cfg.Config)
+	if err != nil {
+		if postErr := notifier.PostError(ctx, err.Error()); postErr != nil {
+			log.Printf("Failed to post error comment: %v", postErr)
+		}
+		return err
+	}
+	if deferred != nil {
+		if err := notifier.PostDeferred(ctx, deferred); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return nil
+	}
+
+	// Show that the command was picked up before doing any work
+	if err := notifier.StartProgress(ctx, cfg.Branches); err != nil {
+		log.Printf("Failed to post progress comment: %v", err)
+	}
 
 	// Process all branches
 	results := service.ProcessBranches(ctx, &cfg.Config)
 
-	// Post results as comments
-	poster.PostResults(ctx, results)
+	// Post or update the summary comment
+	if err := notifier.PostResults(ctx, results); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// The recorded cherry-picks that are done no longer wait for the merge
+	service.ClearDeferred(ctx, &cfg.Config, results)
 
 	// Exit with error if any cherry-pick failed
+	var errs []error
 	for _, result := range results {
-		if !result.Success && result.ExistingPR == nil {
-			return fmt.Errorf("cherry-pick to %s failed", result.Branch)
+		if result.Failed() {
+			errs = append(errs, fmt.Errorf("cherry-pick to %s failed: %w", result.Branch, result.Error))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 type cliConfig struct {
 	cherrypick.Config
 	Token       string
 	IssueNumber int
+	GitTimeout  time.Duration
+	// Backend is "api" to create the commits through the Git Data API when
+	// possible, or "git"
+	Backend string
+	// The signing key is read from SigningKeyFile, or else from the
+	// SigningKeyEnv environment variable
+	SigningFormat  string
+	SigningKeyFile string
+	SigningKeyEnv  string
+}
+
+// loadSigner loads and validates the signing key of cfg. It returns nil when
+// no key is configured.
+func loadSigner(ctx context.Context, cfg *cliConfig) (*cherrypick.Signer, error) {
+	var key []byte
+	switch {
+	case cfg.SigningKeyFile != "":
+		var err error
+		if key, err = os.ReadFile(cfg.SigningKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+	case cfg.SigningKeyEnv != "":
+		key = []byte(os.Getenv(cfg.SigningKeyEnv))
+		if len(key) == 0 {
+			log.Printf("Warning: %s is empty, commits will not be signed", cfg.SigningKeyEnv)
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	signer, err := cherrypick.NewSigner(ctx, cherrypick.SigningFormat(cfg.SigningFormat), key, cfg.GitUserEmail)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	log.Printf("Signing commits with a %s key", signer.Format())
+	return signer, nil
+}
+
+// applyRepoConfig sets the options of cfg that set, the flags given on the
+// command line, leaves alone from the repository configuration
+func applyRepoConfig(cfg *cliConfig, repoCfg *cherrypick.RepoConfig, set map[string]bool) {
+	if !set["git-user-name"] && repoCfg.Bot.Name != "" {
+		cfg.GitUserName = repoCfg.Bot.Name
+	}
+	if !set["git-user-email"] && repoCfg.Bot.Email != "" {
+		cfg.GitUserEmail = repoCfg.Bot.Email
+	}
+	if !set["branch-aliases"] && len(repoCfg.Aliases) > 0 {
+		cfg.BranchAliases = repoCfg.Aliases
+	}
+	if !set["branch-strategy-options"] && len(repoCfg.StrategyOptions) > 0 {
+		cfg.BranchStrategyOptions = repoCfg.StrategyOptions
+	}
+	if !set["concurrency"] && repoCfg.Concurrency > 0 {
+		cfg.Concurrency = repoCfg.Concurrency
+	}
+	if !set["draft-on-conflict"] {
+		cfg.DraftOnConflict = repoCfg.DraftOnConflict
+	}
+	if !set["signoff"] {
+		cfg.Signoff = repoCfg.Signoff
+	}
+	if !set["trailer"] && len(repoCfg.Trailers) > 0 {
+		cfg.Trailers = repoCfg.Trailers
+	}
+	if !set["label-allow"] && len(repoCfg.Labels.Allow) > 0 {
+		cfg.LabelAllow = repoCfg.Labels.Allow
+	}
+	if !set["label-deny"] && len(repoCfg.Labels.Deny) > 0 {
+		cfg.LabelDeny = repoCfg.Labels.Deny
+	}
+	if !set["label-rename"] && len(repoCfg.Labels.Rename) > 0 {
+		cfg.LabelRename = repoCfg.Labels.Rename
+	}
+	if !set["branch-milestones"] && len(repoCfg.Milestones) > 0 {
+		cfg.BranchMilestones = repoCfg.Milestones
+	}
+	if !set["assign-author"] && repoCfg.AssignAuthor != nil {
+		cfg.AssignAuthor = *repoCfg.AssignAuthor
+	}
+	if !set["request-reviews"] && repoCfg.RequestReviews != nil {
+		cfg.RequestReviews = *repoCfg.RequestReviews
+	}
+	if !set["branch-reviewers"] && len(repoCfg.Reviewers) > 0 {
+		cfg.BranchReviewers = repoCfg.Reviewers
+	}
+	if !set["auto-merge"] && repoCfg.AutoMerge != "" {
+		cfg.AutoMerge = repoCfg.AutoMerge
+	}
+	cfg.AllowedBranches = repoCfg.Branches
+	cfg.BranchTemplate = repoCfg.Templates.Branch
+	cfg.TitleTemplate = repoCfg.Templates.Title
+	cfg.BodyTemplate = repoCfg.Templates.Body
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-func parseFlags() (cliConfig, int64) {
+// parseFlags parses the flags in args. It also returns the flags given with a
+// value, which take precedence over the repository configuration.
+func parseFlags(args []string) (cliConfig, int64, map[string]bool) {
 	var (
 		prNumber     = flag.Int("pr-number", 0, "PR number to cherry-pick")
+		commits      = flag.String("commits", "", "Comma-separated list of commit SHAs or SHA..SHA ranges to cherry-pick instead of the whole PR")
 		branches     = flag.String("branches", "", "Comma-separated list of target branches")
 		repo         = flag.String("repo", "", "Repository in owner/name format")
 		commentID    = flag.Int64("comment-id", 0, "Comment ID to add reaction to")
 		issueNumber  = flag.Int("issue-number", 0, "Issue/PR number to comment on")
 		gitUserName  = flag.String("git-user-name", "Shortbrain bot", "Git user name")
 		gitUserEmail = flag.String("git-user-email", "vincent+bot@sbr.pm", "Git user email")
+		recreate     = flag.Bool("recreate", false, "Recreate cherry-pick PRs that were closed without merging")
+		draft        = flag.Bool("draft-on-conflict", false, "Commit conflicts with their markers and open a draft PR instead of failing")
+		strategy     = flag.String("strategy-option", "", "Comma-separated list of merge strategy options (git cherry-pick -X), e.g. theirs")
+		aliases      = flag.String("branch-aliases", "", "Comma-separated list of alias=pattern pairs usable as target branches, e.g. supported=release-v1.*,latest-two=release-v*:2")
+		branchOpts   = flag.String("branch-strategy-options", "", "Comma-separated list of branch=option pairs, used for branches when --strategy-option is empty")
+		rerereCache  = flag.String("rerere-cache", "", "Directory of recorded conflict resolutions (rr-cache) to replay")
+		signoff      = flag.Bool("signoff", false, "Add a Signed-off-by trailer for the bot to the picked commits")
+		signingFmt   = flag.String("signing-format", string(cherrypick.SigningFormatSSH), "Format of the signing key: ssh or openpgp")
+		signingKey   = flag.String("signing-key", "", "Path to an unencrypted private key signing the picked commits")
+		signingEnv   = flag.String("signing-key-env", "", "Environment variable holding the signing key, when --signing-key is not set")
+		backend      = flag.String("backend", "git", "How commits are created: git, or api to use the Git Data API and fall back to git for conflicts")
+		labelAllow   = flag.String("label-allow", "", "Comma-separated list of patterns of the labels copied to the cherry-pick PRs (all when empty)")
+		labelDeny    = flag.String("label-deny", "", "Comma-separated list of patterns of the labels not copied to the cherry-pick PRs")
+		labelRename  = flag.String("label-rename", "", "Comma-separated list of from=to pairs renaming the copied labels")
+		milestones   = flag.String("branch-milestones", "", "Comma-separated list of branch=milestone pairs, branches being patterns, e.g. release-v1.2.*=v1.2.x")
+		assignAuthor = flag.Bool("assign-author", true, "Assign the cherry-pick PRs to the author of the source PR")
+		reviews      = flag.Bool("request-reviews", true, "Request reviews of the cherry-pick PRs from the author and the approvers of the source PR")
+		reviewers    = flag.String("branch-reviewers", "", "Comma-separated list of branch=reviewer pairs, branches being patterns and reviewers logins or org/team, e.g. release-v1.*=org/release-team")
+		autoMerge    = flag.String("auto-merge", "", "Enable auto-merge on the PRs of clean cherry-picks with this method: merge, squash or rebase")
+		concurrency  = flag.Int("concurrency", 0, "Number of branches cherry-picked at once (0 for all)")
+		gitTimeout   = flag.Duration("git-timeout", 10*time.Minute, "Timeout for each git command (0 disables it)")
 	)
 
-	flag.Parse()
+	var trailers stringList
+	flag.Var(&trailers, "trailer", "Trailer added to the picked commits, e.g. \"Backport-Of: #123\" (can be repeated)")
+
+	_ = flag.CommandLine.Parse(args)
+
+	// Workflows pass empty values for unset variables, which must not
+	// override the repository configuration
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Value.String() != "" {
+			set[f.Name] = true
+		}
+	})
 
 	token := os.Getenv("GITHUB_TOKEN")
 	if token == "" {
@@ -102,18 +436,109 @@ func parseFlags() (cliConfig, int64) {
 		}
 	}
 
+	commitList := strings.FieldsFunc(*commits, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	branchStrategyOptions, err := parseBranchOptions(*branchOpts)
+	if err != nil {
+		log.Fatalf("--branch-strategy-options: %v", err)
+	}
+	branchAliases, err := parseBranchOptions(*aliases)
+	if err != nil {
+		log.Fatalf("--branch-aliases: %v", err)
+	}
+
+	branchReviewers, err := parseBranchOptions(*reviewers)
+	if err != nil {
+		log.Fatalf("--branch-reviewers: %v", err)
+	}
+	labelRenames, err := parsePairs(*labelRename)
+	if err != nil {
+		log.Fatalf("--label-rename: %v", err)
+	}
+	branchMilestones, err := parsePairs(*milestones)
+	if err != nil {
+		log.Fatalf("--branch-milestones: %v", err)
+	}
+
 	cfg := cliConfig{
 		Config: cherrypick.Config{
-			PRNumber:     *prNumber,
-			Branches:     branchList,
-			RepoOwner:    parts[0],
-			RepoName:     parts[1],
-			GitUserName:  *gitUserName,
-			GitUserEmail: *gitUserEmail,
+			PRNumber:              *prNumber,
+			Commits:               commitList,
+			Branches:              branchList,
+			RepoOwner:             parts[0],
+			RepoName:              parts[1],
+			GitUserName:           *gitUserName,
+			GitUserEmail:          *gitUserEmail,
+			RecreateStale:         *recreate,
+			DraftOnConflict:       *draft,
+			StrategyOptions:       splitList(*strategy),
+			BranchStrategyOptions: branchStrategyOptions,
+			RerereCache:           *rerereCache,
+			Signoff:               *signoff,
+			Trailers:              trailers,
+			BranchAliases:         branchAliases,
+			Concurrency:           *concurrency,
+			LabelAllow:            splitList(*labelAllow),
+			LabelDeny:             splitList(*labelDeny),
+			LabelRename:           labelRenames,
+			BranchMilestones:      branchMilestones,
+			AssignAuthor:          *assignAuthor,
+			RequestReviews:        *reviews,
+			BranchReviewers:       branchReviewers,
+			AutoMerge:             cherrypick.MergeMethod(*autoMerge),
 		},
-		Token:       token,
-		IssueNumber: *issueNumber,
+		Token:          token,
+		IssueNumber:    *issueNumber,
+		GitTimeout:     *gitTimeout,
+		Backend:        *backend,
+		SigningFormat:  *signingFmt,
+		SigningKeyFile: *signingKey,
+		SigningKeyEnv:  *signingEnv,
+	}
+
+	return cfg, *commentID, set
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
+
+// parseBranchOptions parses a comma-separated list of branch=value pairs, or
+// alias=pattern pairs. A name may appear several times to get several values.
+func parseBranchOptions(s string) (map[string][]string, error) {
+	options := map[string][]string{}
+	for _, pair := range splitList(s) {
+		branch, value, ok := strings.Cut(pair, "=")
+		if !ok || branch == "" || value == "" {
+			return nil, fmt.Errorf("invalid pair %q: expected name=value", pair)
+		}
+		options[branch] = append(options[branch], value)
+	}
+	return options, nil
+}
 
-	return cfg, *commentID
+// parsePairs parses a comma-separated list of name=value pairs, each name
+// appearing once
+func parsePairs(s string) (map[string]string, error) {
+	options, err := parseBranchOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	pairs := map[string]string{}
+	for name, values := range options {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("%q is given several values", name)
+		}
+		pairs[name] = values[0]
+	}
+	return pairs, nil
 }
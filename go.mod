@@ -3,6 +3,8 @@ module github.com/vdemeester/workflows-experiments
 go 1.25.3
 
 require (
-	github.com/google/go-github/v66 v66.0.0 // indirect
-	github.com/google/go-querystring v1.1.0 // indirect
+	github.com/google/go-github/v66 v66.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/google/go-querystring v1.1.0 // indirect
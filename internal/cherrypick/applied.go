@@ -0,0 +1,31 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+// errAlreadyApplied is returned when every commit to pick is already on the
+// target branch, e.g. because it was backported by hand
+var errAlreadyApplied = errors.New("the change is already applied")
+
+// alreadyApplied reports whether the target branch checked out in dir has a
+// commit with the same patch ID as commit, among the ones since their merge
+// base
+func (s *Service) alreadyApplied(ctx context.Context, dir, commit string) bool {
+	output, err := s.runGit(ctx, dir, "cherry", "HEAD", commit, commit+"^")
+	if err != nil {
+		log.Printf("Warning: failed to compare %s with the target branch: %v", shortSHA(commit), err)
+		return false
+	}
+	return strings.HasPrefix(output, "- ")
+}
+
+// isEmptyPick reports whether err comes from a cherry-pick that stopped
+// because it had nothing left to commit
+func isEmptyPick(err error) bool {
+	var gitErr *GitError
+	return errors.As(err, &gitErr) && strings.Contains(gitErr.Stderr, "cherry-pick is now empty")
+}
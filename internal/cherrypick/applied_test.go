@@ -0,0 +1,138 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestProcessBranch_AlreadyAppliedRealGit(t *testing.T) {
+	tests := []struct {
+		name string
+		// backport applies (part of) the PR to release-v1.0 by hand
+		backport func(t *testing.T, repos *realRepos)
+		// wantPicked is the number of picks when the change is only partly
+		// applied
+		wantPicked int
+	}{
+		{
+			name: "same patches",
+			backport: func(t *testing.T, repos *realRepos) {
+				gitRepo(t, repos.clone, append([]string{"cherry-pick"}, repos.prCommits...)...)
+			},
+		},
+		{
+			name: "squashed",
+			backport: func(t *testing.T, repos *realRepos) {
+				writeFile(t, filepath.Join(repos.clone, "fix.txt"), "fix\n")
+				writeFile(t, filepath.Join(repos.clone, "docs.txt"), "docs\n")
+				gitRepo(t, repos.clone, "add", ".")
+				gitRepo(t, repos.clone, "commit", "-m", "Backport fix and docs")
+			},
+		},
+		{
+			name: "partly applied",
+			backport: func(t *testing.T, repos *realRepos) {
+				writeFile(t, filepath.Join(repos.clone, "fix.txt"), "fix\n")
+				gitRepo(t, repos.clone, "add", ".")
+				gitRepo(t, repos.clone, "commit", "-m", "Backport fix")
+			},
+			wantPicked: 1,
+		},
+	}
+
+	for _, backend := range []string{"git", "api"} {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodRebase)
+				gitRepo(t, repos.clone, "checkout", "release-v1.0")
+				gitRepo(t, repos.clone, "config", "user.name", "Maintainer")
+				gitRepo(t, repos.clone, "config", "user.email", "maintainer@test.com")
+				tt.backport(t, repos)
+				gitRepo(t, repos.clone, "push", "origin", "release-v1.0")
+				gitRepo(t, repos.clone, "checkout", "main")
+
+				mockGH := newRealGitHubClient(t, repos)
+				created := false
+				mockGH.createPR = func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+					created = true
+					return &github.PullRequest{Number: intPtr(2)}, nil
+				}
+				var service *Service
+				if backend == "api" {
+					mockGit := &mockGitRunner{runFunc: func(cmd GitCommand) (GitOutput, error) {
+						t.Errorf("Unexpected git command: %v", cmd.Args)
+						return GitOutput{}, errors.New("no clone")
+					}}
+					service = NewService(mockGH, mockGit, WithGitDataClient(&fakeGitData{t: t, repo: repos.origin}))
+				} else {
+					service = NewService(mockGH, &CommandGitRunner{Dir: repos.clone})
+				}
+				cfg := &Config{
+					PRNumber:     1,
+					RepoOwner:    "owner",
+					RepoName:     "repo",
+					GitUserName:  "Test Bot",
+					GitUserEmail: "bot@test.com",
+				}
+
+				result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+				if !result.Success || result.Failed() {
+					t.Fatalf("Expected success, got %s", result.ErrorMessage)
+				}
+
+				branch := "cherry-pick-1-to-release-v1.0"
+				if tt.wantPicked == 0 {
+					if !result.AlreadyApplied {
+						t.Error("Expected the change to be reported as already applied")
+					}
+					if created {
+						t.Error("Expected no PR to be opened")
+					}
+					if err := exec.Command("git", "-C", repos.origin, "rev-parse", "--verify", "--quiet", branch).Run(); err == nil {
+						t.Errorf("Expected no %s branch to be pushed", branch)
+					}
+					return
+				}
+
+				if result.AlreadyApplied || !created {
+					t.Fatal("Expected a PR for the rest of the change")
+				}
+				picked := strings.Fields(gitRepo(t, repos.origin, "rev-list", "release-v1.0.."+branch))
+				if len(picked) != tt.wantPicked {
+					t.Errorf("Expected %d picks, got %d", tt.wantPicked, len(picked))
+				}
+				if docs := gitRepo(t, repos.origin, "show", branch+":docs.txt"); docs != "docs" {
+					t.Errorf("Expected docs.txt to be picked, got %q", docs)
+				}
+			})
+		}
+	}
+}
+
+func TestIsEmptyPick(t *testing.T) {
+	empty := &GitError{Err: errors.New("exit status 1"), Stderr: "The previous cherry-pick is now empty, possibly due to conflict resolution."}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "empty", err: empty, want: true},
+		{name: "wrapped", err: &ConflictError{Err: empty}, want: true},
+		{name: "conflict", err: &GitError{Err: errors.New("exit status 1"), Stderr: "error: could not apply abc123... Fix things"}},
+		{name: "other", err: errors.New("cherry-pick is now empty")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmptyPick(tt.err); got != tt.want {
+				t.Errorf("isEmptyPick() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// enableAutoMergeMutation turns on auto-merge for a pull request. There is no
+// REST endpoint for it.
+const enableAutoMergeMutation = `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $pullRequestId, mergeMethod: $mergeMethod}) {
+    clientMutationId
+  }
+}`
+
+// EnableAutoMerge makes GitHub merge the pull request with the given node ID
+// with method once its requirements, such as checks, are met
+func (c *DefaultGitHubClient) EnableAutoMerge(ctx context.Context, nodeID string, method MergeMethod) error {
+	req, err := c.client.NewRequest(http.MethodPost, graphQLURL(c.client.BaseURL), map[string]any{
+		"query": enableAutoMergeMutation,
+		"variables": map[string]string{
+			"pullRequestId": nodeID,
+			"mergeMethod":   strings.ToUpper(string(method)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// GraphQL reports errors in the body of successful responses
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := c.client.Do(ctx, req, &response); err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range response.Errors {
+		errs = append(errs, errors.New(e.Message))
+	}
+	return errors.Join(errs...)
+}
+
+// graphQLURL returns the GraphQL endpoint of the REST API at base: /graphql
+// on github.com, /api/graphql on GitHub Enterprise Server
+func graphQLURL(base *url.URL) string {
+	if strings.HasSuffix(base.Path, "/api/v3/") {
+		return base.ResolveReference(&url.URL{Path: "../graphql"}).String()
+	}
+	return base.ResolveReference(&url.URL{Path: "graphql"}).String()
+}
+
+// validateAutoMerge checks the merge method auto-merge is enabled with, if any
+func validateAutoMerge(method MergeMethod) error {
+	switch method {
+	case "", MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return nil
+	}
+	return fmt.Errorf("invalid auto-merge method %q: expected %s, %s or %s", method, MergeMethodMerge, MergeMethodSquash, MergeMethodRebase)
+}
+
+// enableAutoMerge turns on auto-merge for pr, a clean cherry-pick PR, when
+// cfg.AutoMerge is set. Failures, e.g. when the repository does not allow
+// auto-merge, are only reported as warnings of result.
+func (s *Service) enableAutoMerge(ctx context.Context, cfg *Config, result *Result, pr *github.PullRequest) {
+	if cfg.AutoMerge == "" {
+		return
+	}
+	if err := s.github.EnableAutoMerge(ctx, pr.GetNodeID(), cfg.AutoMerge); err != nil {
+		result.warn("failed to enable auto-merge on #%d: %v", pr.GetNumber(), err)
+		return
+	}
+	result.AutoMerge = cfg.AutoMerge
+}
@@ -0,0 +1,170 @@
+package cherrypick
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestGraphQLURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://api.github.com/", "https://api.github.com/graphql"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+	}
+
+	for _, tt := range tests {
+		base, err := url.Parse(tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := graphQLURL(base); got != tt.want {
+			t.Errorf("graphQLURL(%s) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestEnableAutoMerge(t *testing.T) {
+	var request struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	var response string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	gh := NewDefaultGitHubClient(client)
+
+	response = `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`
+	if err := gh.EnableAutoMerge(context.Background(), "PR_kwDOA", MergeMethodSquash); err != nil {
+		t.Fatalf("EnableAutoMerge() error = %v", err)
+	}
+	if !strings.Contains(request.Query, "enablePullRequestAutoMerge") {
+		t.Errorf("Unexpected query %q", request.Query)
+	}
+	if request.Variables["pullRequestId"] != "PR_kwDOA" || request.Variables["mergeMethod"] != "SQUASH" {
+		t.Errorf("Unexpected variables %v", request.Variables)
+	}
+
+	// Errors come with a successful status
+	response = `{"data": null, "errors": [{"message": "Pull request Auto merge is not allowed for this repository"}]}`
+	err := gh.EnableAutoMerge(context.Background(), "PR_kwDOA", MergeMethodSquash)
+	if err == nil || !strings.Contains(err.Error(), "Auto merge is not allowed") {
+		t.Errorf("Expected the GraphQL error, got %v", err)
+	}
+}
+
+func TestProcessBranch_AutoMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		autoMerge MergeMethod
+		err       error
+		want      MergeMethod
+		warnings  int
+	}{
+		{name: "disabled"},
+		{name: "enabled", autoMerge: MergeMethodRebase, want: MergeMethodRebase},
+		{name: "not allowed", autoMerge: MergeMethodSquash, err: errors.New("auto merge is not allowed"), warnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled := false
+			mockGH := &mockGitHubClient{
+				getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+					return &github.PullRequest{Merged: boolPtr(true), MergeCommitSHA: stringPtr("abc123")}, nil
+				},
+				findExistingPR: func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+					return nil, nil
+				},
+				createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+					return &github.PullRequest{Number: intPtr(789), NodeID: stringPtr("PR_789")}, nil
+				},
+				autoMerge: func(ctx context.Context, nodeID string, method MergeMethod) error {
+					if nodeID != "PR_789" || method != tt.autoMerge {
+						t.Errorf("Unexpected auto-merge of %s with %s", nodeID, method)
+					}
+					enabled = true
+					return tt.err
+				},
+			}
+			service := NewService(mockGH, &mockGitRunner{})
+
+			cfg := &Config{
+				PRNumber:     123,
+				RepoOwner:    "owner",
+				RepoName:     "repo",
+				GitUserName:  "Test Bot",
+				GitUserEmail: "bot@test.com",
+				AutoMerge:    tt.autoMerge,
+			}
+
+			result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+			if !result.Success {
+				t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+			}
+			if enabled != (tt.autoMerge != "") {
+				t.Errorf("Expected auto-merge to be requested: %v, got %v", tt.autoMerge != "", enabled)
+			}
+			if result.AutoMerge != tt.want {
+				t.Errorf("Expected auto-merge %q, got %q", tt.want, result.AutoMerge)
+			}
+			if len(result.Warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestProcessBranch_NoAutoMergeOnConflicts(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodSquash)
+	addConflicts(t, repos, "release-v1.0", "fix.txt")
+
+	mockGH := newRealGitHubClient(t, repos)
+	mockGH.createPR = func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+		return &github.PullRequest{Number: intPtr(2), NodeID: stringPtr("PR_2")}, nil
+	}
+	mockGH.autoMerge = func(ctx context.Context, nodeID string, method MergeMethod) error {
+		t.Errorf("Unexpected auto-merge of the draft PR %s", nodeID)
+		return nil
+	}
+
+	service := NewService(mockGH, &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:        1,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		DraftOnConflict: true,
+		AutoMerge:       MergeMethodSquash,
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if result.DraftPR.GetNumber() != 2 {
+		t.Fatalf("Expected draft PR #2, got %+v (%s)", result.DraftPR, result.ErrorMessage)
+	}
+	if result.AutoMerge != "" {
+		t.Errorf("Expected no auto-merge, got %q", result.AutoMerge)
+	}
+}
@@ -0,0 +1,263 @@
+package cherrypick
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// ListBranches returns the names of the branches of a repository
+func (c *DefaultGitHubClient) ListBranches(ctx context.Context, owner, repo string) ([]string, error) {
+	var all []string
+	opts := &github.BranchListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		branches, resp, err := c.client.Repositories.ListBranches(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			all = append(all, branch.GetName())
+		}
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ResolveBranches expands the aliases and globs of cfg.Branches into the
+// branches to cherry-pick to, without duplicates.
+//
+// An alias stands for the branches of its patterns. A pattern is a branch
+// name or a glob such as release-v1.*, whose matches are the allowed branches
+// in version order (release-v1.10 comes after release-v1.9). A ":N" suffix keeps only the N
+// latest matches, e.g. release-v*:2. The branches of the repository are only
+// listed when a glob needs them.
+func (s *Service) ResolveBranches(ctx context.Context, cfg *Config) ([]string, error) {
+	var resolved, branches []string
+	for _, entry := range cfg.Branches {
+		patterns := []string{entry}
+		if alias, ok := cfg.BranchAliases[entry]; ok {
+			patterns = alias
+		}
+
+		for _, pattern := range patterns {
+			glob, latest, err := parseBranchPattern(pattern)
+			if err != nil {
+				return nil, err
+			}
+			if !isGlob(glob) && latest == 0 {
+				resolved = append(resolved, glob)
+				continue
+			}
+
+			if branches == nil {
+				if branches, err = s.repoBranches(ctx, cfg); err != nil {
+					return nil, fmt.Errorf("failed to list branches: %w", err)
+				}
+			}
+
+			var matches []string
+			for _, branch := range branches {
+				if ok, _ := path.Match(glob, branch); ok && cfg.allowsBranch(branch) {
+					matches = append(matches, branch)
+				}
+			}
+			if len(matches) == 0 {
+				return nil, &BranchNotFoundError{Branch: pattern, Suggestions: suggestBranches(glob, branches)}
+			}
+			if latest > 0 && len(matches) > latest {
+				matches = matches[len(matches)-latest:]
+			}
+			resolved = append(resolved, matches...)
+		}
+	}
+
+	var unique []string
+	for _, branch := range resolved {
+		if !slices.Contains(unique, branch) {
+			unique = append(unique, branch)
+		}
+	}
+	return unique, nil
+}
+
+// repoBranches returns the branches of the repository of cfg in version
+// order. They are listed once per service, as a run does not expect target
+// branches to come and go.
+func (s *Service) repoBranches(ctx context.Context, cfg *Config) ([]string, error) {
+	s.branchesMu.Lock()
+	defer s.branchesMu.Unlock()
+
+	repo := cfg.RepoOwner + "/" + cfg.RepoName
+	if branches, ok := s.branches[repo]; ok {
+		return branches, nil
+	}
+	branches, err := s.github.ListBranches(ctx, cfg.RepoOwner, cfg.RepoName)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(branches, compareVersions)
+	if s.branches == nil {
+		s.branches = map[string][]string{}
+	}
+	s.branches[repo] = branches
+	return branches, nil
+}
+
+// checkBranch returns a BranchNotFoundError when targetBranch does not exist.
+// When the branches cannot be listed, the check is left to the git
+// operations.
+func (s *Service) checkBranch(ctx context.Context, cfg *Config, targetBranch string) error {
+	branches, err := s.repoBranches(ctx, cfg)
+	if err != nil {
+		log.Printf("Warning: failed to list branches, not checking %s: %v", targetBranch, err)
+		return nil
+	}
+	if slices.Contains(branches, targetBranch) {
+		return nil
+	}
+	return &BranchNotFoundError{Branch: targetBranch, Suggestions: suggestBranches(targetBranch, branches)}
+}
+
+// maxSuggestions is the number of branches suggested for a missing one
+const maxSuggestions = 3
+
+// suggestBranches returns the branches whose name is close to name: within a
+// few edits of it, or starting with it. The closest come first.
+func suggestBranches(name string, branches []string) []string {
+	type candidate struct {
+		branch   string
+		distance int
+	}
+	// Allow about one typo per four characters
+	maxDistance := max(2, len(name)/4)
+
+	var candidates []candidate
+	for _, branch := range branches {
+		distance := editDistance(name, branch)
+		if distance <= maxDistance || strings.HasPrefix(branch, strings.TrimRight(name, "*?[")) {
+			candidates = append(candidates, candidate{branch, distance})
+		}
+	}
+	// The branches are in version order, which a stable sort keeps between
+	// equally close ones
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+
+	var suggestions []string
+	for _, c := range candidates[:min(len(candidates), maxSuggestions)] {
+		suggestions = append(suggestions, c.branch)
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// allowsBranch reports whether branch matches one of cfg.AllowedBranches, if
+// any
+func (cfg *Config) allowsBranch(branch string) bool {
+	return len(cfg.AllowedBranches) == 0 || matchesAny(cfg.AllowedBranches, branch)
+}
+
+// validateAlias checks the name and the patterns of a branch alias
+func validateAlias(alias string, patterns []string) error {
+	if alias == "" || isGlob(alias) || strings.Contains(alias, ":") {
+		return fmt.Errorf("invalid branch alias %q", alias)
+	}
+	for _, pattern := range patterns {
+		if _, _, err := parseBranchPattern(pattern); err != nil {
+			return fmt.Errorf("%s: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// parseBranchPattern splits the ":N" suffix off a branch pattern. Branch
+// names cannot contain ':', so the suffix is never part of a name.
+func parseBranchPattern(pattern string) (string, int, error) {
+	glob, count, found := strings.Cut(pattern, ":")
+	latest := 0
+	if found {
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			return "", 0, fmt.Errorf("invalid branch pattern %q: expected a positive count after ':'", pattern)
+		}
+		latest = n
+	}
+	if _, err := path.Match(glob, ""); glob == "" || err != nil {
+		return "", 0, fmt.Errorf("invalid branch pattern %q", pattern)
+	}
+	return glob, latest, nil
+}
+
+// isGlob reports whether pattern has glob metacharacters
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// compareVersions orders branch names with their numbers compared by value,
+// so that release-v1.10 comes after release-v1.9
+func compareVersions(a, b string) int {
+	restA, restB := a, b
+	for restA != "" && restB != "" {
+		var chunkA, chunkB string
+		chunkA, restA = versionChunk(restA)
+		chunkB, restB = versionChunk(restB)
+		numA, errA := strconv.ParseUint(chunkA, 10, 64)
+		numB, errB := strconv.ParseUint(chunkB, 10, 64)
+		if errA == nil && errB == nil && numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(chunkA, chunkB); c != 0 && (errA != nil || errB != nil) {
+			return c
+		}
+	}
+	// Numbers written differently, like 01 and 1, are equal but the names
+	// still need an order
+	return cmp.Or(strings.Compare(restA, restB), strings.Compare(a, b))
+}
+
+// versionChunk splits the leading run of digits or of non-digits off s
+func versionChunk(s string) (string, string) {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
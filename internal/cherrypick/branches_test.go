@@ -0,0 +1,222 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestResolveBranches(t *testing.T) {
+	remote := []string{"main", "release-v1.9", "release-v1.10", "release-v1.2", "release-v2.0", "feature/release-v3.0"}
+	aliases := map[string][]string{
+		"supported":  {"release-v1.10", "release-v2.*"},
+		"latest-two": {"release-v*:2"},
+	}
+
+	tests := []struct {
+		name     string
+		branches []string
+		want     []string
+		wantErr  error
+		noList   bool
+	}{
+		{
+			name:     "names",
+			branches: []string{"release-v1.0", "main"},
+			want:     []string{"release-v1.0", "main"},
+			noList:   true,
+		},
+		{
+			name:     "glob in version order",
+			branches: []string{"release-v1.*"},
+			want:     []string{"release-v1.2", "release-v1.9", "release-v1.10"},
+		},
+		{
+			name:     "aliases",
+			branches: []string{"supported", "latest-two"},
+			want:     []string{"release-v1.10", "release-v2.0"},
+		},
+		{
+			name:     "latest",
+			branches: []string{"release-v1.*:1", "main"},
+			want:     []string{"release-v1.10", "main"},
+		},
+		{
+			name:     "no match",
+			branches: []string{"release-v4.*"},
+			wantErr:  ErrBranchNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			mockGH := &mockGitHubClient{
+				listBranches: func(ctx context.Context, owner, repo string) ([]string, error) {
+					listed = true
+					return slices.Clone(remote), nil
+				},
+			}
+			service := NewService(mockGH, &mockGitRunner{})
+			cfg := &Config{Branches: tt.branches, BranchAliases: aliases, RepoOwner: "owner", RepoName: "repo"}
+
+			got, err := service.ResolveBranches(context.Background(), cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveBranches() error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ResolveBranches() = %v, want %v", got, tt.want)
+			}
+			if tt.noList && listed {
+				t.Error("Expected branches not to be listed without globs")
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	sorted := []string{"main", "release-1.2.3", "release-v1.2", "release-v1.9", "release-v1.10", "release-v1.10.1", "release-v2.0", "release-v10.0"}
+	shuffled := slices.Clone(sorted)
+	slices.Reverse(shuffled)
+	slices.SortFunc(shuffled, compareVersions)
+	if !slices.Equal(shuffled, sorted) {
+		t.Errorf("Expected %v, got %v", sorted, shuffled)
+	}
+}
+
+func TestParseBranchPattern(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		wantGlob   string
+		wantLatest int
+		wantErr    bool
+	}{
+		{pattern: "release-v1.0", wantGlob: "release-v1.0"},
+		{pattern: "release-v*:2", wantGlob: "release-v*", wantLatest: 2},
+		{pattern: "release-v*:0", wantErr: true},
+		{pattern: "release-v*:two", wantErr: true},
+		{pattern: "release-[v", wantErr: true},
+		{pattern: ":2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		glob, latest, err := parseBranchPattern(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBranchPattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			continue
+		}
+		if glob != tt.wantGlob || latest != tt.wantLatest {
+			t.Errorf("parseBranchPattern(%q) = %q, %d, want %q, %d", tt.pattern, glob, latest, tt.wantGlob, tt.wantLatest)
+		}
+	}
+}
+
+func TestSuggestBranches(t *testing.T) {
+	branches := []string{"main", "release-v1.0", "release-v1.1", "release-v1.10", "release-v2.0", "docs"}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "relase-v1.0", want: []string{"release-v1.0", "release-v1.1", "release-v1.10"}},
+		{name: "release-v2.O", want: []string{"release-v2.0", "release-v1.0", "release-v1.1"}},
+		{name: "release-v1", want: []string{"release-v1.0", "release-v1.1", "release-v1.10"}},
+		{name: "mian", want: []string{"main"}},
+		{name: "feature-x", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := suggestBranches(tt.name, branches); !slices.Equal(got, tt.want) {
+			t.Errorf("suggestBranches(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"main", "main", 0},
+		{"main", "mian", 2},
+		{"relase", "release", 1},
+		{"release-v1.0", "release-v1.10", 1},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestProcessBranch_BranchNotFound(t *testing.T) {
+	listed := 0
+	mockGH := &mockGitHubClient{
+		listBranches: func(ctx context.Context, owner, repo string) ([]string, error) {
+			listed++
+			return []string{"main", "release-v1.0"}, nil
+		},
+	}
+	mockGit := &mockGitRunner{}
+	service := NewService(mockGH, mockGit)
+	cfg := &Config{PRNumber: 123, RepoOwner: "owner", RepoName: "repo", Branches: []string{"relase-v1.0", "release-v1.1"}}
+
+	results := service.ProcessBranches(context.Background(), cfg)
+
+	for _, result := range results {
+		var notFound *BranchNotFoundError
+		if !errors.As(result.Error, &notFound) || !errors.Is(result.Error, ErrBranchNotFound) {
+			t.Fatalf("Expected a BranchNotFoundError, got %v", result.Error)
+		}
+		if notFound.Branch != result.Branch || !slices.Equal(notFound.Suggestions, []string{"release-v1.0"}) {
+			t.Errorf("Unexpected error %+v", notFound)
+		}
+		if want := "target branch '" + result.Branch + "' does not exist (did you mean 'release-v1.0'?)"; result.ErrorMessage != want {
+			t.Errorf("Expected %q, got %q", want, result.ErrorMessage)
+		}
+	}
+	if listed != 1 {
+		t.Errorf("Expected the branches to be listed once, got %d", listed)
+	}
+	if len(mockGit.commands) > 0 {
+		t.Errorf("Expected no git commands, got %v", mockGit.commands)
+	}
+}
+
+func TestAllowedBranches(t *testing.T) {
+	mockGH := &mockGitHubClient{
+		listBranches: func(ctx context.Context, owner, repo string) ([]string, error) {
+			return []string{"main", "release-v0.9", "release-v1.0", "release-v1.1"}, nil
+		},
+	}
+	mockGit := &mockGitRunner{}
+	service := NewService(mockGH, mockGit)
+	cfg := &Config{
+		PRNumber:        123,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		Branches:        []string{"release-v*"},
+		AllowedBranches: []string{"release-v1.*"},
+	}
+
+	// Globs only expand to allowed branches
+	branches, err := service.ResolveBranches(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ResolveBranches() error = %v", err)
+	}
+	if want := []string{"release-v1.0", "release-v1.1"}; !slices.Equal(branches, want) {
+		t.Errorf("ResolveBranches() = %v, want %v", branches, want)
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v0.9")
+	if !errors.Is(result.Error, ErrBranchNotAllowed) {
+		t.Fatalf("Expected ErrBranchNotAllowed, got %v", result.Error)
+	}
+	if len(mockGit.commands) > 0 {
+		t.Errorf("Expected no git commands, got %v", mockGit.commands)
+	}
+}
@@ -1,50 +1,218 @@
 package cherrypick
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"path"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/v66/github"
 )
 
 // Config holds the configuration for cherry-pick operations
 type Config struct {
-	PRNumber     int
+	PRNumber int
+	// Commits lists commit SHAs or A..B ranges to pick instead of a whole
+	// PR. When set, PRNumber is ignored.
+	Commits []string
+	// Branches are the target branches: names, globs such as release-v1.*
+	// or aliases. See Service.ResolveBranches.
 	Branches     []string
 	RepoOwner    string
 	RepoName     string
 	GitUserName  string
 	GitUserEmail string
+	// RecreateStale recreates cherry-pick PRs that were closed without
+	// being merged, force-resetting their branch.
+	RecreateStale bool
+	// DraftOnConflict commits conflicting picks with their conflict markers
+	// and opens a draft PR for the author to finish, instead of failing.
+	DraftOnConflict bool
+	// StrategyOptions are passed to git cherry-pick as -X options, e.g.
+	// "theirs" or "ignore-space-change".
+	StrategyOptions []string
+	// BranchStrategyOptions are the strategy options of a target branch,
+	// used when StrategyOptions is empty.
+	BranchStrategyOptions map[string][]string
+	// RerereCache is a directory of recorded conflict resolutions (a
+	// shared rr-cache) replayed when a pick conflicts.
+	RerereCache string
+	// Signoff adds a Signed-off-by trailer for the bot to the picked
+	// commits.
+	Signoff bool
+	// Trailers are "Token: value" trailers added to the picked commits, e.g.
+	// "Backport-Of: #123".
+	Trailers []string
+	// BranchAliases maps alias names, usable in Branches, to the branch
+	// patterns they stand for.
+	BranchAliases map[string][]string
+	// AllowedBranches are the patterns of the branches that can be
+	// cherry-picked to. Any branch can when empty.
+	AllowedBranches []string
+	// Concurrency is the number of branches processed at once by
+	// ProcessBranches, 0 for all of them.
+	Concurrency int
+	// BranchTemplate, TitleTemplate and BodyTemplate are text/templates
+	// of the branch name, title and body of the PR picks, executed with a
+	// TemplateData. Empty ones use the defaults.
+	BranchTemplate string
+	TitleTemplate  string
+	BodyTemplate   string
+	// LabelAllow and LabelDeny are patterns of the labels of the source PR
+	// copied to its cherry-pick PRs, or not. All of them are copied when
+	// LabelAllow is empty.
+	LabelAllow []string
+	LabelDeny  []string
+	// LabelRename maps source labels to the labels of the cherry-pick PRs
+	LabelRename map[string]string
+	// BranchMilestones maps target branches, or patterns of them, to the
+	// milestone of their cherry-pick PRs
+	BranchMilestones map[string]string
+	// AssignAuthor assigns the cherry-pick PRs to the author of the source
+	// PR.
+	AssignAuthor bool
+	// RequestReviews requests reviews of the cherry-pick PRs from the author
+	// and the approvers of the source PR.
+	RequestReviews bool
+	// BranchReviewers maps target branch patterns to reviewers of their
+	// cherry-pick PRs, logins or org/team
+	BranchReviewers map[string][]string
+	// AutoMerge enables auto-merge with this method on the PRs of clean
+	// cherry-picks. It is disabled when empty.
+	AutoMerge MergeMethod
 }
 
 // Result represents the outcome of a cherry-pick operation
 type Result struct {
-	Branch       string
-	Success      bool
-	ExistingPR   *github.PullRequest
-	NewPR        *github.PullRequest
+	Branch     string
+	Success    bool
+	ExistingPR *github.PullRequest
+	// MergedPR is a cherry-pick PR that was already merged
+	MergedPR *github.PullRequest
+	// StalePR is a cherry-pick PR that was closed without being merged. It
+	// is set along NewPR when the PR was recreated.
+	StalePR *github.PullRequest
+	NewPR   *github.PullRequest
+	// DraftPR is the draft PR holding the conflicting pick, opened with
+	// Config.DraftOnConflict
+	DraftPR     *github.PullRequest
+	MergeMethod MergeMethod
+	// AutoMerge is the method NewPR will be merged with once its checks
+	// pass, empty when auto-merge was not enabled
+	AutoMerge MergeMethod
+	// Conflicts lists the conflicting files when the cherry-pick failed on
+	// conflicts
+	Conflicts []ConflictFile
+	// AlreadyApplied is set when the branch already has the change, so no
+	// PR was opened
+	AlreadyApplied bool
+	// Warnings are the non-fatal failures of an otherwise successful
+	// cherry-pick, e.g. a label that could not be added
+	Warnings     []string
 	Error        error
 	ErrorMessage string
 }
 
+// Failed reports whether the cherry-pick failed. An open or stale
+// cherry-pick PR that was left alone is not a failure, but failing to
+// recreate a stale one is.
+func (r *Result) Failed() bool {
+	return !r.Success && r.ExistingPR == nil && (r.StalePR == nil || r.Error != nil)
+}
+
+// warn logs a non-fatal failure and records it in the warnings of r
+func (r *Result) warn(format string, args ...any) {
+	warning := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", warning)
+	r.Warnings = append(r.Warnings, warning)
+}
+
+// GitCommand describes a single git invocation
+type GitCommand struct {
+	Args []string
+	// Dir is the directory git runs in. Empty means the runner's default.
+	Dir string
+	// Env holds extra KEY=value pairs added to the inherited environment.
+	Env []string
+}
+
+// GitOutput holds what a git invocation printed
+type GitOutput struct {
+	Stdout string
+	Stderr string
+}
+
+// GitError is returned by CommandGitRunner when git fails. It keeps the
+// output so callers can inspect what went wrong.
+type GitError struct {
+	Args   []string
+	Err    error
+	Stdout string
+	Stderr string
+}
+
+func (e *GitError) Error() string {
+	output := strings.TrimSpace(e.Stdout + "\n" + e.Stderr)
+	return fmt.Sprintf("git %s: %v: %s", strings.Join(e.Args, " "), e.Err, output)
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
 // GitRunner defines the interface for git operations
 type GitRunner interface {
-	Run(args ...string) error
+	Run(ctx context.Context, cmd GitCommand) (GitOutput, error)
 }
 
 // CommandGitRunner runs actual git commands
-type CommandGitRunner struct{}
+type CommandGitRunner struct {
+	// Dir is the repository the commands run in when GitCommand.Dir is
+	// empty. Empty means the current working directory.
+	Dir string
+	// Timeout bounds every command on top of the context deadline. Zero
+	// means no timeout.
+	Timeout time.Duration
+}
+
+func (r *CommandGitRunner) Run(ctx context.Context, command GitCommand) (GitOutput, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
 
-func (r *CommandGitRunner) Run(args ...string) error {
-	cmd := exec.Command("git", args...)
-	output, err := cmd.CombinedOutput()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", command.Args...)
+	cmd.Dir = r.Dir
+	if command.Dir != "" {
+		cmd.Dir = command.Dir
+	}
+	if len(command.Env) > 0 {
+		cmd.Env = append(os.Environ(), command.Env...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// git may spawn helpers (ssh, hooks) that keep the pipes open after
+	// git itself has been killed.
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	output := GitOutput{Stdout: stdout.String(), Stderr: stderr.String()}
 	if err != nil {
-		return fmt.Errorf("%w: %s", err, string(output))
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = fmt.Errorf("%w (%v)", ctxErr, err)
+		}
+		return output, &GitError{Args: command.Args, Err: err, Stdout: output.Stdout, Stderr: output.Stderr}
 	}
-	return nil
+	return output, nil
 }
 
 // GitHubClient defines the interface for GitHub operations
@@ -52,6 +220,17 @@ type GitHubClient interface {
 	GetPR(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error)
 	FindExistingPR(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error)
 	CreatePR(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error)
+	ListPRCommits(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error)
+	GetCommit(ctx context.Context, owner, repo, sha string) (*github.Commit, error)
+	CompareCommits(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error)
+	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error
+	AddAssignees(ctx context.Context, owner, repo string, number int, assignees []string) error
+	SetMilestone(ctx context.Context, owner, repo string, number int, title string) error
+	ListBranches(ctx context.Context, owner, repo string) ([]string, error)
+	ListReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error)
+	RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers, teams []string) error
+	EnableAutoMerge(ctx context.Context, nodeID string, method MergeMethod) error
+	RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error
 }
 
 // DefaultGitHubClient wraps the go-github client
@@ -95,30 +274,123 @@ func (c *DefaultGitHubClient) CreatePR(ctx context.Context, owner, repo string,
 	return newPR, err
 }
 
+// ListPRCommits returns the commits of a pull request, oldest first. GitHub
+// caps the list at 250 commits.
+func (c *DefaultGitHubClient) ListPRCommits(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	var all []*github.RepositoryCommit
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		commits, resp, err := c.client.PullRequests.ListCommits(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, commits...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetCommit returns a commit by SHA. Unlike the Git Data API, the commits
+// endpoint also resolves abbreviated SHAs.
+func (c *DefaultGitHubClient) GetCommit(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+	rc, _, err := c.client.Repositories.GetCommit(ctx, owner, repo, sha, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := rc.GetCommit()
+	return &github.Commit{
+		SHA:       rc.SHA,
+		Message:   commit.Message,
+		Author:    commit.Author,
+		Committer: commit.Committer,
+		Tree:      commit.Tree,
+		Parents:   rc.Parents,
+	}, nil
+}
+
+// CompareCommits returns the commits reachable from head but not from base,
+// oldest first. GitHub caps the list at 250 commits.
+func (c *DefaultGitHubClient) CompareCommits(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+	var all []*github.RepositoryCommit
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comparison, resp, err := c.client.Repositories.CompareCommits(ctx, owner, repo, base, head, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, comparison.Commits...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// AddLabels adds labels to an issue or pull request
+func (c *DefaultGitHubClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	_, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
+	return err
+}
+
 // Service handles cherry-pick operations
 type Service struct {
-	github GitHubClient
-	git    GitRunner
+	github   GitHubClient
+	git      GitRunner
+	progress ProgressReporter
+	signer   *Signer
+	gitData  GitDataClient
+
+	// repoMu serializes the commands touching the shared repository
+	// (fetch, worktree and branch management). git does not support these
+	// concurrently: a fetch fails on a worktree that is being added, and
+	// config writes race on the config lock.
+	repoMu sync.Mutex
+
+	// branches caches the branches of the repositories, by owner/name
+	branchesMu sync.Mutex
+	branches   map[string][]string
 }
 
 // NewService creates a new cherry-pick service
-func NewService(github GitHubClient, git GitRunner) *Service {
-	return &Service{
+func NewService(github GitHubClient, git GitRunner, opts ...Option) *Service {
+	s := &Service{
 		github: github,
 		git:    git,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// ProcessBranches processes multiple branches concurrently
+// ProcessBranches processes multiple branches concurrently, at most
+// cfg.Concurrency at a time
 func (s *Service) ProcessBranches(ctx context.Context, cfg *Config) []*Result {
 	var wg sync.WaitGroup
 	results := make([]*Result, len(cfg.Branches))
 
+	limit := cfg.Concurrency
+	if limit <= 0 {
+		limit = len(cfg.Branches)
+	}
+	slots := make(chan struct{}, limit)
+
+	// The commits to pick are the same for every branch: they are worked
+	// out once, by the first branch needing them
+	plan := sync.OnceValues(func() (*pickPlan, error) {
+		return s.planPicks(ctx, cfg)
+	})
+
 	for i, branch := range cfg.Branches {
 		wg.Add(1)
 		go func(index int, targetBranch string) {
 			defer wg.Done()
-			results[index] = s.ProcessBranch(ctx, cfg, targetBranch)
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[index] = s.processBranch(ctx, cfg, targetBranch, plan)
 		}(i, branch)
 	}
 
@@ -128,54 +400,110 @@ func (s *Service) ProcessBranches(ctx context.Context, cfg *Config) []*Result {
 
 // ProcessBranch handles cherry-picking to a single branch
 func (s *Service) ProcessBranch(ctx context.Context, cfg *Config, targetBranch string) *Result {
+	return s.processBranch(ctx, cfg, targetBranch, func() (*pickPlan, error) {
+		return s.planPicks(ctx, cfg)
+	})
+}
+
+// processBranch cherry-picks the commits of planPicks to targetBranch. The
+// plan is only asked for once the branch is known to be a valid target.
+func (s *Service) processBranch(ctx context.Context, cfg *Config, targetBranch string, planPicks func() (*pickPlan, error)) *Result {
 	result := &Result{
 		Branch:  targetBranch,
 		Success: false,
 	}
 
 	log.Printf("🤖 Starting cherry-pick to %s...", targetBranch)
+	defer func() {
+		s.report(ctx, Event{Branch: targetBranch, Stage: StageDone, Result: result})
+	}()
 
-	// Get PR information
-	pr, err := s.github.GetPR(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
-	if err != nil {
+	if !cfg.allowsBranch(targetBranch) {
+		result.Error = classify(ErrBranchNotAllowed, fmt.Errorf("target branch '%s' is not allowed: expected one of %s", targetBranch, strings.Join(cfg.AllowedBranches, ", ")))
+		result.ErrorMessage = result.Error.Error()
+		return result
+	}
+
+	// Catch misspelled branches before any work
+	if err := s.checkBranch(ctx, cfg, targetBranch); err != nil {
 		result.Error = err
-		result.ErrorMessage = fmt.Sprintf("Failed to fetch PR #%d: %v", cfg.PRNumber, err)
+		result.ErrorMessage = err.Error()
 		return result
 	}
 
-	// Check if PR is merged
-	if pr.Merged == nil || !*pr.Merged {
-		result.ErrorMessage = fmt.Sprintf("PR #%d is not merged yet (state: %s). Cherry-pick requires merged PRs.", cfg.PRNumber, pr.GetState())
+	// Work out which commits carry the change
+	plan, err := planPicks()
+	if err != nil {
+		result.Error = err
+		result.ErrorMessage = err.Error()
 		return result
 	}
+	result.MergeMethod = plan.method
 
-	mergeCommit := pr.GetMergeCommitSHA()
-	log.Printf("Found merge commit: %s", mergeCommit)
+	cherryPickBranch, err := plan.branchName(cfg, targetBranch)
+	if err != nil {
+		result.Error = err
+		result.ErrorMessage = err.Error()
+		return result
+	}
+	title, body, err := plan.describe(cfg, targetBranch)
+	if err != nil {
+		result.Error = err
+		result.ErrorMessage = err.Error()
+		return result
+	}
 
 	// Check if cherry-pick PR already exists
-	cherryPickBranch := fmt.Sprintf("cherry-pick-%d-to-%s", cfg.PRNumber, targetBranch)
 	existingPR, err := s.github.FindExistingPR(ctx, cfg.RepoOwner, cfg.RepoName, cherryPickBranch, targetBranch)
 	if err != nil {
 		log.Printf("Warning: error checking for existing PR: %v", err)
 	}
 
-	if existingPR != nil {
+	switch {
+	case existingPR == nil:
+	case existingPR.MergedAt != nil || existingPR.GetMerged():
+		log.Printf("ℹ️  Cherry-pick PR already merged: #%d", existingPR.GetNumber())
+		result.Success = true
+		result.MergedPR = existingPR
+		return result
+	case existingPR.GetState() == "closed":
+		log.Printf("ℹ️  Cherry-pick PR was closed without merging: #%d", existingPR.GetNumber())
+		result.StalePR = existingPR
+		if !cfg.RecreateStale {
+			return result
+		}
+		log.Printf("Recreating cherry-pick PR, resetting %s...", cherryPickBranch)
+	default:
 		log.Printf("ℹ️  Cherry-pick PR already exists: #%d", existingPR.GetNumber())
 		result.Success = true
 		result.ExistingPR = existingPR
 		return result
 	}
 
-	// Perform git operations
-	if err := s.performGitOperations(cfg, targetBranch, cherryPickBranch, mergeCommit); err != nil {
+	// Create the cherry-pick branch. A stale PR's branch still exists on
+	// the remote and is overwritten.
+	conflicts, err := s.createBranch(ctx, cfg, targetBranch, cherryPickBranch, plan, result.StalePR != nil)
+	if errors.Is(err, errAlreadyApplied) {
+		log.Printf("ℹ️  The change is already applied on %s", targetBranch)
+		result.Success = true
+		result.AlreadyApplied = true
+		return result
+	}
+	if err != nil {
 		result.Error = err
 		result.ErrorMessage = err.Error()
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			result.Conflicts = conflictErr.Files
+		}
 		return result
 	}
 
 	// Create pull request
-	title := fmt.Sprintf("Cherry-pick #%d to %s", cfg.PRNumber, targetBranch)
-	body := fmt.Sprintf("Automatic cherry-pick of #%d to `%s`", cfg.PRNumber, targetBranch)
+	if len(conflicts) > 0 {
+		s.openDraftPR(ctx, cfg, result, plan.pr, cherryPickBranch, title, body, conflicts)
+		return result
+	}
 
 	newPR, err := s.github.CreatePR(ctx, cfg.RepoOwner, cfg.RepoName, &github.NewPullRequest{
 		Title: &title,
@@ -185,65 +513,343 @@ func (s *Service) ProcessBranch(ctx context.Context, cfg *Config, targetBranch s
 	})
 
 	if err != nil {
-		result.Error = err
+		result.Error = classify(ErrPRCreationFailed, err)
 		result.ErrorMessage = fmt.Sprintf("Failed to create pull request: %v", err)
 		return result
 	}
 
+	s.copyMetadata(ctx, cfg, result, plan.pr, newPR)
+	s.requestReviews(ctx, cfg, result, plan.pr, newPR)
+	s.enableAutoMerge(ctx, cfg, result, newPR)
+
 	log.Printf("✅ Cherry-pick completed successfully! PR #%d created", newPR.GetNumber())
+	s.report(ctx, Event{Branch: targetBranch, Stage: StagePROpened, PR: newPR})
 	result.Success = true
 	result.NewPR = newPR
 	return result
 }
 
-func (s *Service) performGitOperations(cfg *Config, targetBranch, cherryPickBranch, mergeCommit string) error {
-	// Configure git
-	if err := s.git.Run("config", "user.name", cfg.GitUserName); err != nil {
-		return fmt.Errorf("failed to configure git user name: %w", err)
+// planPicks resolves the commits to cherry-pick, either from the requested
+// commits or from the merged PR.
+func (s *Service) planPicks(ctx context.Context, cfg *Config) (*pickPlan, error) {
+	if len(cfg.Commits) > 0 {
+		plan, err := s.planCommitPicks(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve commits: %w", err)
+		}
+		log.Printf("Picking %d commit(s)", len(plan.commits))
+		return plan, nil
+	}
+
+	// Get PR information
+	pr, err := s.github.GetPR(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR #%d: %w", cfg.PRNumber, err)
 	}
 
-	if err := s.git.Run("config", "user.email", cfg.GitUserEmail); err != nil {
-		return fmt.Errorf("failed to configure git user email: %w", err)
+	// Check if PR is merged
+	if pr.Merged == nil || !*pr.Merged {
+		return nil, classify(ErrNotMerged, fmt.Errorf("PR #%d is not merged yet (state: %s). Cherry-pick requires merged PRs", cfg.PRNumber, pr.GetState()))
 	}
 
-	// Fetch target branch
+	mergeCommit := pr.GetMergeCommitSHA()
+	log.Printf("Found merge commit: %s", mergeCommit)
+
+	plan, err := s.planPRPicks(ctx, cfg, mergeCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine how PR #%d was merged: %w", cfg.PRNumber, err)
+	}
+	plan.pr = pr
+	log.Printf("PR #%d was merged with method %q, picking %d commit(s)", cfg.PRNumber, plan.method, len(plan.commits))
+	return plan, nil
+}
+
+// performGitOperations cherry-picks the commits of plan onto targetBranch and
+// pushes the result as cherryPickBranch, overwriting it when force is set. The
+// work happens in a dedicated worktree so that concurrent calls for different
+// branches never share HEAD or the index.
+//
+// With cfg.DraftOnConflict, conflicting picks are committed with their
+// markers and the conflicts are returned along a nil error.
+//
+// The picks use the strategy options of targetBranch and replay the
+// resolutions of cfg.RerereCache. Commits already on targetBranch are
+// skipped, and errAlreadyApplied is returned when all of them are.
+func (s *Service) performGitOperations(ctx context.Context, cfg *Config, targetBranch, cherryPickBranch string, plan *pickPlan, force bool) ([]ConflictFile, error) {
+	// Fetch target branch. Only the remote-tracking ref of this branch is
+	// updated, and FETCH_HEAD is left alone as it is shared between worktrees.
 	log.Printf("Fetching target branch: %s...", targetBranch)
-	if err := s.git.Run("fetch", "origin", targetBranch); err != nil {
-		return fmt.Errorf("target branch '%s' does not exist or cannot be fetched: %w", targetBranch, err)
+	s.report(ctx, Event{Branch: targetBranch, Stage: StageFetching})
+	// A shallow clone, as used along the API, only gets the tip of the
+	// target branch.
+	shallow, _ := s.runRepoGit(ctx, "rev-parse", "--is-shallow-repository")
+	fetchArgs := []string{"fetch", "--no-write-fetch-head"}
+	if shallow == "true" {
+		fetchArgs = append(fetchArgs, "--depth=1")
+	}
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", targetBranch, targetBranch)
+	if _, err := s.runRepoGit(ctx, append(fetchArgs, "origin", refspec)...); err != nil {
+		err = fmt.Errorf("target branch '%s' does not exist or cannot be fetched: %w", targetBranch, err)
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && strings.Contains(gitErr.Stderr, "couldn't find remote ref") {
+			err = classify(ErrBranchNotFound, err)
+		}
+		return nil, err
+	}
+
+	// It may also lack the commits to pick: they are fetched along with
+	// their parents, which cherry-pick diffs against.
+	if shallow == "true" {
+		args := append([]string{"fetch", "--no-write-fetch-head", "--depth=2", "origin"}, plan.commits...)
+		if _, err := s.runRepoGit(ctx, args...); err != nil {
+			return nil, fmt.Errorf("failed to fetch the commits to cherry-pick: %w", err)
+		}
+	}
+
+	worktree, err := os.MkdirTemp("", "cherry-pick-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
 	}
+	defer os.RemoveAll(worktree)
 
-	// Create new branch for cherry-pick
+	// Create new branch for cherry-pick in its own worktree. Upstream
+	// tracking would be written to the shared repository config, where
+	// concurrent writers fail to take the lock.
 	log.Printf("Creating cherry-pick branch: %s...", cherryPickBranch)
-	if err := s.git.Run("checkout", "-b", cherryPickBranch, fmt.Sprintf("origin/%s", targetBranch)); err != nil {
-		return fmt.Errorf("failed to create cherry-pick branch: %w", err)
+	if _, err := s.runRepoGit(ctx, "worktree", "add", "--no-track", "-b", cherryPickBranch, worktree, fmt.Sprintf("origin/%s", targetBranch)); err != nil {
+		return nil, fmt.Errorf("failed to create cherry-pick branch: %w", err)
 	}
+	defer s.removeWorktree(worktree, cherryPickBranch)
 
 	// Perform cherry-pick
-	log.Printf("Cherry-picking commit %s...", mergeCommit)
-	if err := s.git.Run("cherry-pick", "-m", "1", mergeCommit); err != nil {
+	log.Printf("Cherry-picking %s...", strings.Join(plan.commits, ", "))
+	s.report(ctx, Event{Branch: targetBranch, Stage: StagePicking})
+	if cfg.RerereCache != "" {
+		if err := s.useRerereCache(ctx, cfg.RerereCache); err != nil {
+			return nil, err
+		}
+	}
+	conflicts, err := s.pickCommits(ctx, cfg, worktree, targetBranch, plan)
+	if err != nil {
 		// Abort cherry-pick on failure
-		_ = s.git.Run("cherry-pick", "--abort")
-		return fmt.Errorf("cherry-pick failed due to conflicts or other errors: %w", err)
+		_, _ = s.runGit(ctx, worktree, "cherry-pick", "--abort")
+		return nil, err
 	}
 
 	// Push the new branch
 	log.Printf("Pushing cherry-pick branch...")
-	if err := s.git.Run("push", "origin", cherryPickBranch); err != nil {
-		return fmt.Errorf("failed to push cherry-pick branch: %w", err)
+	s.report(ctx, Event{Branch: targetBranch, Stage: StagePushing})
+	pushArgs := []string{"push", "origin", cherryPickBranch}
+	if force {
+		pushArgs = append(pushArgs, "--force")
+	}
+	if _, err := s.runGit(ctx, worktree, pushArgs...); err != nil {
+		return nil, classify(ErrPushRejected, fmt.Errorf("failed to push cherry-pick branch: %w", err))
+	}
+
+	return conflicts, nil
+}
+
+// pickCommits cherry-picks the commits of plan one at a time in dir, recording
+// where they come from and adding the configured trailers. It returns the
+// conflicts committed with cfg.DraftOnConflict.
+//
+// Commits with the same patch ID as a commit of targetBranch, and picks left
+// empty, are skipped as already applied.
+func (s *Service) pickCommits(ctx context.Context, cfg *Config, dir, targetBranch string, plan *pickPlan) ([]ConflictFile, error) {
+	var conflicts []ConflictFile
+	applied := 0
+	for _, commit := range plan.commits {
+		// Patch IDs are not defined for merge commits, whose picks can only
+		// turn out empty
+		if !plan.mainline && s.alreadyApplied(ctx, dir, commit) {
+			log.Printf("%s is already applied on %s, skipping it", shortSHA(commit), targetBranch)
+			applied++
+			continue
+		}
+
+		args := []string{"cherry-pick", "-x"}
+		if plan.mainline {
+			args = append(args, "-m", "1")
+		}
+		for _, option := range cfg.strategyOptions(targetBranch) {
+			args = append(args, "-X", option)
+		}
+		args = append(args, commit)
+		if _, err := s.git.Run(ctx, s.pickCommand(cfg, dir, args...)); err != nil {
+			err = fmt.Errorf("cherry-pick of %s failed due to conflicts or other errors: %w", shortSHA(commit), err)
+			// Deal with the conflicts before the abort throws them away
+			more, err := s.resolveConflicts(ctx, cfg, dir, err)
+			if isEmptyPick(err) {
+				log.Printf("Cherry-pick of %s is empty, it is already applied on %s", shortSHA(commit), targetBranch)
+				if _, err := s.runGit(ctx, dir, "cherry-pick", "--skip"); err != nil {
+					return nil, fmt.Errorf("failed to skip the empty cherry-pick of %s: %w", shortSHA(commit), err)
+				}
+				applied++
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			conflicts = mergeConflicts(conflicts, more)
+		}
+
+		if !cfg.Signoff && len(cfg.Trailers) == 0 {
+			continue
+		}
+		args = []string{"commit", "--amend", "--no-edit", "--no-verify"}
+		if cfg.Signoff {
+			args = append(args, "--signoff")
+		}
+		for _, trailer := range cfg.Trailers {
+			args = append(args, "--trailer", trailer)
+		}
+		if _, err := s.git.Run(ctx, s.pickCommand(cfg, dir, args...)); err != nil {
+			return nil, fmt.Errorf("failed to add trailers to %s: %w", shortSHA(commit), err)
+		}
 	}
 
+	if applied == len(plan.commits) {
+		return nil, errAlreadyApplied
+	}
+	return conflicts, nil
+}
+
+// pickCommand returns a git command creating picked commits in dir. git
+// keeps the original author of the commits and the bot is their committer.
+// The identity and the signing key are passed per command rather than
+// written to the repository config, which all worktrees share.
+func (s *Service) pickCommand(cfg *Config, dir string, args ...string) GitCommand {
+	var config []string
+	if cfg.RerereCache != "" {
+		// Resolutions replayed by rerere are staged, so that picks whose
+		// conflicts were all known can be continued
+		config = append(config, "-c", "rerere.enabled=true", "-c", "rerere.autoUpdate=true")
+	}
+	env := []string{
+		"GIT_COMMITTER_NAME=" + cfg.GitUserName,
+		"GIT_COMMITTER_EMAIL=" + cfg.GitUserEmail,
+		// Keep the message of the picked commit instead of opening an
+		// editor when continuing
+		"GIT_EDITOR=true",
+		// Empty picks are recognized by their message
+		"LC_ALL=C",
+	}
+	if s.signer != nil {
+		config = append(config, s.signer.gitConfig()...)
+		env = append(env, s.signer.env...)
+	}
+	return GitCommand{
+		Args: append(config, args...),
+		Dir:  dir,
+		Env:  env,
+	}
+}
+
+// validateTrailer checks that trailer has the "Token: value" form
+func validateTrailer(trailer string) error {
+	token, value, ok := strings.Cut(trailer, ":")
+	if !ok || token == "" || strings.ContainsAny(token, " \t") || strings.TrimSpace(value) == "" || strings.Contains(trailer, "\n") {
+		return fmt.Errorf("invalid trailer %q: expected \"Token: value\"", trailer)
+	}
 	return nil
 }
 
+// removeWorktree deletes the worktree and the local branch created by
+// performGitOperations. Failures are only logged: the branch has been pushed
+// (or the operation already failed) by the time this runs. It does not use
+// the request context so that cleanup still happens after a cancellation.
+func (s *Service) removeWorktree(worktree, cherryPickBranch string) {
+	ctx := context.Background()
+	if _, err := s.runRepoGit(ctx, "worktree", "remove", "--force", worktree); err != nil {
+		log.Printf("Warning: failed to remove worktree %s: %v", worktree, err)
+	}
+	if _, err := s.runRepoGit(ctx, "branch", "-D", cherryPickBranch); err != nil {
+		log.Printf("Warning: failed to delete local branch %s: %v", cherryPickBranch, err)
+	}
+}
+
+// runGit runs git in dir and returns its trimmed standard output
+func (s *Service) runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	output, err := s.git.Run(ctx, GitCommand{Args: args, Dir: dir})
+	return strings.TrimSpace(output.Stdout), err
+}
+
+// runRepoGit runs git in the shared repository, one command at a time
+func (s *Service) runRepoGit(ctx context.Context, args ...string) (string, error) {
+	s.repoMu.Lock()
+	defer s.repoMu.Unlock()
+	return s.runGit(ctx, "", args...)
+}
+
 // ValidateConfig validates the cherry-pick configuration
 func ValidateConfig(cfg *Config) error {
-	if cfg.PRNumber == 0 {
-		return fmt.Errorf("PR number is required")
+	if cfg.PRNumber == 0 && len(cfg.Commits) == 0 {
+		return fmt.Errorf("PR number or commits are required")
+	}
+
+	for _, commit := range cfg.Commits {
+		if err := validateCommitSpec(commit); err != nil {
+			return err
+		}
+	}
+
+	for _, option := range cfg.StrategyOptions {
+		if err := validateStrategyOption(option); err != nil {
+			return err
+		}
+	}
+	for _, trailer := range cfg.Trailers {
+		if err := validateTrailer(trailer); err != nil {
+			return err
+		}
+	}
+
+	for branch, options := range cfg.BranchStrategyOptions {
+		for _, option := range options {
+			if err := validateStrategyOption(option); err != nil {
+				return fmt.Errorf("%s: %w", branch, err)
+			}
+		}
+	}
+
+	for alias, patterns := range cfg.BranchAliases {
+		if err := validateAlias(alias, patterns); err != nil {
+			return err
+		}
+	}
+	if err := validateTemplates(cfg.BranchTemplate, cfg.TitleTemplate, cfg.BodyTemplate); err != nil {
+		return err
+	}
+	for _, pattern := range cfg.AllowedBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed branch pattern %q", pattern)
+		}
+	}
+	if err := validateLabels(cfg.LabelAllow, cfg.LabelDeny, cfg.LabelRename); err != nil {
+		return err
+	}
+	if err := validateMilestones(cfg.BranchMilestones); err != nil {
+		return err
+	}
+	if err := validateReviewers(cfg.BranchReviewers); err != nil {
+		return err
+	}
+	if err := validateAutoMerge(cfg.AutoMerge); err != nil {
+		return err
 	}
 
 	if len(cfg.Branches) == 0 {
 		return fmt.Errorf("at least one target branch is required")
 	}
+	for _, branch := range cfg.Branches {
+		if _, ok := cfg.BranchAliases[branch]; ok {
+			continue
+		}
+		if _, _, err := parseBranchPattern(branch); err != nil {
+			return err
+		}
+	}
 
 	if cfg.RepoOwner == "" || cfg.RepoName == "" {
 		return fmt.Errorf("repository owner and name are required")
@@ -3,7 +3,14 @@ package cherrypick
 import (
 	"context"
 	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v66/github"
 )
@@ -11,9 +18,20 @@ import (
 // Mock implementations for testing
 
 type mockGitHubClient struct {
-	getPR            func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error)
-	findExistingPR   func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error)
-	createPR         func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error)
+	getPR          func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error)
+	findExistingPR func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error)
+	createPR       func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error)
+	listPRCommits  func(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error)
+	getCommit      func(ctx context.Context, owner, repo, sha string) (*github.Commit, error)
+	compareCommits func(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error)
+	addLabels      func(ctx context.Context, owner, repo string, number int, labels []string) error
+	listBranches   func(ctx context.Context, owner, repo string) ([]string, error)
+	addAssignees   func(ctx context.Context, owner, repo string, number int, assignees []string) error
+	setMilestone   func(ctx context.Context, owner, repo string, number int, title string) error
+	listReviews    func(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error)
+	requestReviews func(ctx context.Context, owner, repo string, number int, reviewers, teams []string) error
+	autoMerge      func(ctx context.Context, nodeID string, method MergeMethod) error
+	removeLabel    func(ctx context.Context, owner, repo string, number int, label string) error
 }
 
 func (m *mockGitHubClient) GetPR(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
@@ -37,21 +55,116 @@ func (m *mockGitHubClient) CreatePR(ctx context.Context, owner, repo string, pr
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockGitHubClient) ListPRCommits(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	if m.listPRCommits != nil {
+		return m.listPRCommits(ctx, owner, repo, number)
+	}
+	return nil, nil
+}
+
+// GetCommit defaults to a merge commit so that tests not caring about the
+// merge method get a single pick.
+func (m *mockGitHubClient) GetCommit(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+	if m.getCommit != nil {
+		return m.getCommit(ctx, owner, repo, sha)
+	}
+	return &github.Commit{
+		SHA:     stringPtr(sha),
+		Parents: []*github.Commit{{SHA: stringPtr("parent1")}, {SHA: stringPtr("parent2")}},
+	}, nil
+}
+
+func (m *mockGitHubClient) CompareCommits(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+	if m.compareCommits != nil {
+		return m.compareCommits(ctx, owner, repo, base, head)
+	}
+	return nil, nil
+}
+
+func (m *mockGitHubClient) ListBranches(ctx context.Context, owner, repo string) ([]string, error) {
+	if m.listBranches != nil {
+		return m.listBranches(ctx, owner, repo)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockGitHubClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+	if m.addLabels != nil {
+		return m.addLabels(ctx, owner, repo, number, labels)
+	}
+	return nil
+}
+
+func (m *mockGitHubClient) AddAssignees(ctx context.Context, owner, repo string, number int, assignees []string) error {
+	if m.addAssignees != nil {
+		return m.addAssignees(ctx, owner, repo, number, assignees)
+	}
+	return nil
+}
+
+func (m *mockGitHubClient) SetMilestone(ctx context.Context, owner, repo string, number int, title string) error {
+	if m.setMilestone != nil {
+		return m.setMilestone(ctx, owner, repo, number, title)
+	}
+	return nil
+}
+
+func (m *mockGitHubClient) ListReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	if m.listReviews != nil {
+		return m.listReviews(ctx, owner, repo, number)
+	}
+	return nil, nil
+}
+
+func (m *mockGitHubClient) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers, teams []string) error {
+	if m.requestReviews != nil {
+		return m.requestReviews(ctx, owner, repo, number, reviewers, teams)
+	}
+	return nil
+}
+
+func (m *mockGitHubClient) EnableAutoMerge(ctx context.Context, nodeID string, method MergeMethod) error {
+	if m.autoMerge != nil {
+		return m.autoMerge(ctx, nodeID, method)
+	}
+	return nil
+}
+
+func (m *mockGitHubClient) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	if m.removeLabel != nil {
+		return m.removeLabel(ctx, owner, repo, number, label)
+	}
+	return nil
+}
+
 type mockGitRunner struct {
-	commands [][]string
-	runFunc  func(args ...string) error
+	mu       sync.Mutex
+	commands []GitCommand
+	runFunc  func(cmd GitCommand) (GitOutput, error)
 }
 
-func (m *mockGitRunner) Run(args ...string) error {
-	m.commands = append(m.commands, args)
+func (m *mockGitRunner) Run(ctx context.Context, cmd GitCommand) (GitOutput, error) {
+	m.mu.Lock()
+	m.commands = append(m.commands, cmd)
+	m.mu.Unlock()
 	if m.runFunc != nil {
-		return m.runFunc(args...)
+		return m.runFunc(cmd)
 	}
-	return nil
+	return GitOutput{}, nil
 }
 
 // Helper functions
 
+// gitSubcommand strips the -c <key=value> options that precede the git
+// subcommand.
+func gitSubcommand(cmd GitCommand) []string {
+	args := cmd.Args
+	for len(args) >= 2 && args[0] == "-c" {
+		args = args[2:]
+	}
+	return args
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
@@ -64,6 +177,10 @@ func intPtr(i int) *int {
 	return &i
 }
 
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
 // Tests
 
 func TestValidateConfig(t *testing.T) {
@@ -91,6 +208,147 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "commits instead of PR number",
+			cfg: &Config{
+				Commits:   []string{"abc1234", "0123abc..def4567"},
+				Branches:  []string{"main"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid commit",
+			cfg: &Config{
+				Commits:   []string{"abc1234..HEAD"},
+				Branches:  []string{"main"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "strategy options",
+			cfg: &Config{
+				PRNumber:              123,
+				Branches:              []string{"main"},
+				RepoOwner:             "owner",
+				RepoName:              "repo",
+				StrategyOptions:       []string{"theirs", "rename-threshold=50"},
+				BranchStrategyOptions: map[string][]string{"main": {"ignore-space-change"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid strategy option",
+			cfg: &Config{
+				PRNumber:        123,
+				Branches:        []string{"main"},
+				RepoOwner:       "owner",
+				RepoName:        "repo",
+				StrategyOptions: []string{"--exec=evil"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "trailers",
+			cfg: &Config{
+				PRNumber:  123,
+				Branches:  []string{"main"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+				Trailers:  []string{"Backport-Of: #123", "Reviewed-by: Someone <someone@test.com>"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid trailer",
+			cfg: &Config{
+				PRNumber:  123,
+				Branches:  []string{"main"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+				Trailers:  []string{"Backport of #123"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid branch strategy option",
+			cfg: &Config{
+				PRNumber:              123,
+				Branches:              []string{"main"},
+				RepoOwner:             "owner",
+				RepoName:              "repo",
+				BranchStrategyOptions: map[string][]string{"main": {"mine"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid label pattern",
+			cfg: &Config{
+				PRNumber:   123,
+				Branches:   []string{"main"},
+				RepoOwner:  "owner",
+				RepoName:   "repo",
+				LabelAllow: []string{"kind/["},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid milestone branch pattern",
+			cfg: &Config{
+				PRNumber:         123,
+				Branches:         []string{"main"},
+				RepoOwner:        "owner",
+				RepoName:         "repo",
+				BranchMilestones: map[string]string{"release-[": "v1.0.x"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid auto-merge method",
+			cfg: &Config{
+				PRNumber:  123,
+				Branches:  []string{"main"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+				AutoMerge: "fast-forward",
+			},
+			wantErr: true,
+		},
+		{
+			name: "branch globs and aliases",
+			cfg: &Config{
+				PRNumber:      123,
+				Branches:      []string{"release-v1.*", "latest-two"},
+				RepoOwner:     "owner",
+				RepoName:      "repo",
+				BranchAliases: map[string][]string{"latest-two": {"release-v*:2"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid branch pattern",
+			cfg: &Config{
+				PRNumber:  123,
+				Branches:  []string{"release-v*:0"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid branch alias",
+			cfg: &Config{
+				PRNumber:      123,
+				Branches:      []string{"main"},
+				RepoOwner:     "owner",
+				RepoName:      "repo",
+				BranchAliases: map[string][]string{"release-*": {"release-v1.0"}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing branches",
 			cfg: &Config{
@@ -210,6 +468,146 @@ func TestProcessBranch_ExistingPR(t *testing.T) {
 	}
 }
 
+func TestProcessBranch_MergedExistingPR(t *testing.T) {
+	mergedPR := &github.PullRequest{
+		Number:   intPtr(456),
+		State:    stringPtr("closed"),
+		MergedAt: &github.Timestamp{},
+	}
+
+	mockGH := &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{
+				Merged:         boolPtr(true),
+				MergeCommitSHA: stringPtr("abc123"),
+			}, nil
+		},
+		findExistingPR: func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+			return mergedPR, nil
+		},
+	}
+
+	mockGit := &mockGitRunner{}
+	service := NewService(mockGH, mockGit)
+
+	cfg := &Config{
+		PRNumber:      123,
+		RepoOwner:     "owner",
+		RepoName:      "repo",
+		RecreateStale: true,
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release")
+
+	if !result.Success {
+		t.Error("Expected success when the cherry-pick PR is already merged")
+	}
+
+	if result.MergedPR != mergedPR || result.ExistingPR != nil || result.StalePR != nil {
+		t.Errorf("Expected only MergedPR to be set, got %+v", result)
+	}
+
+	if len(mockGit.commands) > 0 {
+		t.Error("Expected no git commands when PR is already merged")
+	}
+}
+
+func TestProcessBranch_StalePR(t *testing.T) {
+	stalePR := &github.PullRequest{
+		Number: intPtr(456),
+		State:  stringPtr("closed"),
+	}
+
+	tests := []struct {
+		name       string
+		recreate   bool
+		pushFails  bool
+		wantFailed bool
+	}{
+		{name: "reported", recreate: false},
+		{name: "recreated", recreate: true},
+		{name: "recreation failed", recreate: true, pushFails: true, wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGH := &mockGitHubClient{
+				getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+					return &github.PullRequest{
+						Merged:         boolPtr(true),
+						MergeCommitSHA: stringPtr("abc123"),
+					}, nil
+				},
+				findExistingPR: func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+					return stalePR, nil
+				},
+				createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+					return &github.PullRequest{Number: intPtr(789)}, nil
+				},
+			}
+
+			mockGit := &mockGitRunner{}
+			if tt.pushFails {
+				mockGit.runFunc = func(cmd GitCommand) (GitOutput, error) {
+					if cmd.Args[0] == "push" {
+						return GitOutput{}, errors.New("! [remote rejected] (protected branch hook declined)")
+					}
+					return GitOutput{}, nil
+				}
+			}
+			service := NewService(mockGH, mockGit)
+
+			cfg := &Config{
+				PRNumber:      123,
+				RepoOwner:     "owner",
+				RepoName:      "repo",
+				RecreateStale: tt.recreate,
+			}
+
+			result := service.ProcessBranch(context.Background(), cfg, "release")
+
+			if result.StalePR != stalePR {
+				t.Error("Expected StalePR to be set")
+			}
+
+			if result.ExistingPR != nil {
+				t.Error("Expected a stale PR not to be reported as existing")
+			}
+
+			if tt.wantFailed {
+				if !result.Failed() || !errors.Is(result.Error, ErrPushRejected) {
+					t.Errorf("Expected the failed recreation to be a failure, got %v", result.Error)
+				}
+				return
+			}
+			if result.Failed() {
+				t.Errorf("Expected a stale PR not to be a failure: %s", result.ErrorMessage)
+			}
+
+			if !tt.recreate {
+				if result.Success || result.NewPR != nil || len(mockGit.commands) > 0 {
+					t.Error("Expected the stale PR to be left alone")
+				}
+				return
+			}
+
+			if !result.Success || result.NewPR.GetNumber() != 789 {
+				t.Fatal("Expected the cherry-pick PR to be recreated")
+			}
+
+			forced := false
+			for _, cmd := range mockGit.commands {
+				if cmd.Args[0] == "push" && slices.Contains(cmd.Args, "--force") {
+					forced = true
+				}
+			}
+			if !forced {
+				t.Error("Expected the cherry-pick branch to be force-pushed")
+			}
+		})
+	}
+}
+
 func TestProcessBranch_Success(t *testing.T) {
 	newPR := &github.PullRequest{
 		Number:  intPtr(789),
@@ -257,18 +655,31 @@ func TestProcessBranch_Success(t *testing.T) {
 	}
 
 	// Verify git commands were called
-	// Should have: config (user.name), config (user.email), fetch, checkout, cherry-pick, push
-	expectedNumCommands := 6
-	if len(mockGit.commands) != expectedNumCommands {
-		t.Errorf("Expected %d git commands, got %d", expectedNumCommands, len(mockGit.commands))
+	// Should have: shallow check, fetch, worktree add, cherry-pick, push, worktree remove, branch -D
+	expected := []string{"rev-parse", "fetch", "worktree", "cherry-pick", "push", "worktree", "branch"}
+	if len(mockGit.commands) != len(expected) {
+		t.Fatalf("Expected %d git commands, got %d", len(expected), len(mockGit.commands))
 	}
 
-	// Verify config commands (first two should be config)
-	if len(mockGit.commands) > 0 && mockGit.commands[0][0] != "config" {
-		t.Error("Expected first command to be 'config'")
+	for i, cmd := range mockGit.commands {
+		if sub := gitSubcommand(cmd)[0]; sub != expected[i] {
+			t.Errorf("Command %d: expected '%s', got '%s'", i, expected[i], sub)
+		}
 	}
-	if len(mockGit.commands) > 1 && mockGit.commands[1][0] != "config" {
-		t.Error("Expected second command to be 'config'")
+
+	// The identity must not be written to the shared repository config
+	for _, cmd := range mockGit.commands {
+		if gitSubcommand(cmd)[0] == "config" {
+			t.Errorf("Unexpected git config command: %v", cmd.Args)
+		}
+	}
+
+	// Fetch and worktree management run in the repository, the rest in the worktree
+	for i, cmd := range mockGit.commands {
+		inWorktree := cmd.Dir != ""
+		if wantWorktree := i == 3 || i == 4; inWorktree != wantWorktree {
+			t.Errorf("Command %d (%v): unexpected directory %q", i, cmd.Args, cmd.Dir)
+		}
 	}
 }
 
@@ -286,11 +697,11 @@ func TestProcessBranch_GitFetchFails(t *testing.T) {
 	}
 
 	mockGit := &mockGitRunner{
-		runFunc: func(args ...string) error {
-			if args[0] == "fetch" {
-				return errors.New("fetch failed: branch not found")
+		runFunc: func(cmd GitCommand) (GitOutput, error) {
+			if cmd.Args[0] == "fetch" {
+				return GitOutput{}, errors.New("fetch failed: branch not found")
 			}
-			return nil
+			return GitOutput{}, nil
 		},
 	}
 
@@ -329,11 +740,11 @@ func TestProcessBranch_CherryPickFails(t *testing.T) {
 	}
 
 	mockGit := &mockGitRunner{
-		runFunc: func(args ...string) error {
-			if args[0] == "cherry-pick" {
-				return errors.New("cherry-pick failed: conflicts")
+		runFunc: func(cmd GitCommand) (GitOutput, error) {
+			if gitSubcommand(cmd)[0] == "cherry-pick" {
+				return GitOutput{}, errors.New("cherry-pick failed: conflicts")
 			}
-			return nil
+			return GitOutput{}, nil
 		},
 	}
 
@@ -360,7 +771,8 @@ func TestProcessBranch_CherryPickFails(t *testing.T) {
 	// Verify abort was called
 	foundAbort := false
 	for _, cmd := range mockGit.commands {
-		if len(cmd) >= 2 && cmd[0] == "cherry-pick" && cmd[1] == "--abort" {
+		args := gitSubcommand(cmd)
+		if len(args) >= 2 && args[0] == "cherry-pick" && args[1] == "--abort" {
 			foundAbort = true
 			break
 		}
@@ -418,3 +830,555 @@ func TestProcessBranches_Concurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessBranches_PlansOnce(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	count := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[call]++
+	}
+
+	mockGH := &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			count("GetPR")
+			return &github.PullRequest{Merged: boolPtr(true), MergeCommitSHA: stringPtr("abc123")}, nil
+		},
+		getCommit: func(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+			count("GetCommit")
+			return &github.Commit{
+				SHA:     stringPtr(sha),
+				Parents: []*github.Commit{{SHA: stringPtr("parent1")}, {SHA: stringPtr("parent2")}},
+			}, nil
+		},
+		findExistingPR: func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+			return nil, nil
+		},
+		createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+			return &github.PullRequest{Number: intPtr(1)}, nil
+		},
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:     123,
+		Branches:     []string{"release-1.0", "release-2.0", "release-3.0"},
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+	}
+
+	for _, result := range service.ProcessBranches(context.Background(), cfg) {
+		if !result.Success {
+			t.Errorf("Expected success for %s, got error: %v", result.Branch, result.ErrorMessage)
+		}
+	}
+
+	// The PR and its merge commit are looked up once for all the branches
+	if calls["GetPR"] != 1 || calls["GetCommit"] != 1 {
+		t.Errorf("Expected the picks to be planned once, got %v", calls)
+	}
+}
+
+func TestProcessBranches_Concurrency(t *testing.T) {
+	mockGH := &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{Merged: boolPtr(true), MergeCommitSHA: stringPtr("abc123")}, nil
+		},
+		createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+			return &github.PullRequest{Number: intPtr(1)}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	mockGit := &mockGitRunner{runFunc: func(cmd GitCommand) (GitOutput, error) {
+		// Count the branches between their pick and their push
+		switch gitSubcommand(cmd)[0] {
+		case "cherry-pick":
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		case "push":
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}
+		return GitOutput{}, nil
+	}}
+	service := NewService(mockGH, mockGit)
+
+	cfg := &Config{
+		PRNumber:     123,
+		Branches:     []string{"release-1.0", "release-2.0", "release-3.0", "release-4.0", "release-5.0"},
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		Concurrency:  2,
+	}
+
+	for _, result := range service.ProcessBranches(context.Background(), cfg) {
+		if !result.Success {
+			t.Errorf("Expected success for %s, got %s", result.Branch, result.ErrorMessage)
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 branches at once, got %d", maxRunning)
+	}
+}
+
+// Real git tests
+
+func TestCommandGitRunner_Output(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	runner := &CommandGitRunner{Dir: dir}
+	ctx := context.Background()
+
+	if _, err := runner.Run(ctx, GitCommand{Args: []string{"init", "-b", "main"}}); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+
+	// Stdout is captured and the runner's directory is used by default
+	output, err := runner.Run(ctx, GitCommand{Args: []string{"rev-parse", "--show-toplevel"}})
+	if err != nil {
+		t.Fatalf("rev-parse failed: %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if got := strings.TrimSpace(output.Stdout); got != resolved {
+		t.Errorf("Expected toplevel %q, got %q", resolved, got)
+	}
+
+	// The command directory overrides the runner's one
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	output, err = runner.Run(ctx, GitCommand{Args: []string{"rev-parse", "--show-prefix"}, Dir: sub})
+	if err != nil {
+		t.Fatalf("rev-parse failed: %v", err)
+	}
+	if got := strings.TrimSpace(output.Stdout); got != "sub/" {
+		t.Errorf("Expected prefix 'sub/', got %q", got)
+	}
+
+	// Environment variables are passed through
+	output, err = runner.Run(ctx, GitCommand{
+		Args: []string{"var", "GIT_COMMITTER_IDENT"},
+		Env:  []string{"GIT_COMMITTER_NAME=Env Bot", "GIT_COMMITTER_EMAIL=env@test.com"},
+	})
+	if err != nil {
+		t.Fatalf("git var failed: %v", err)
+	}
+	if !strings.HasPrefix(output.Stdout, "Env Bot <env@test.com>") {
+		t.Errorf("Expected committer from environment, got %q", output.Stdout)
+	}
+
+	// Failures keep stderr separately and in the error
+	output, err = runner.Run(ctx, GitCommand{Args: []string{"rev-parse", "does-not-exist"}})
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("Expected a GitError, got %v", err)
+	}
+	if output.Stderr == "" || gitErr.Stderr != output.Stderr {
+		t.Errorf("Expected stderr to be captured, got %q", output.Stderr)
+	}
+	if !strings.Contains(err.Error(), "does-not-exist") {
+		t.Errorf("Expected git output in error, got %v", err)
+	}
+}
+
+func TestCommandGitRunner_Timeout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	runner := &CommandGitRunner{Dir: t.TempDir(), Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	_, err := runner.Run(context.Background(), GitCommand{
+		Args: []string{"-c", "alias.hang=!sleep 10", "hang"},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 8*time.Second {
+		t.Errorf("Expected the command to be cancelled, took %s", elapsed)
+	}
+}
+
+// gitRepo runs git in dir and returns the trimmed output, failing the test
+// on error.
+func gitRepo(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// realRepos is a bare origin and a full clone of it
+type realRepos struct {
+	origin      string
+	clone       string
+	mergeCommit string
+	// prCommits are the commits of the feature branch, oldest first
+	prCommits []string
+}
+
+// setupRealRepos creates a bare origin with a main branch and the given
+// release branches, each carrying its own VERSION file. A feature branch with
+// two commits (adding fix.txt and docs.txt) is merged into main using method.
+func setupRealRepos(t *testing.T, branches []string, method MergeMethod) *realRepos {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	// Isolate from the user's git configuration
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	root := t.TempDir()
+	repos := &realRepos{
+		origin: filepath.Join(root, "origin.git"),
+		clone:  filepath.Join(root, "clone"),
+	}
+	seed := filepath.Join(root, "seed")
+
+	gitRepo(t, root, "init", "--bare", "-b", "main", repos.origin)
+	gitRepo(t, root, "init", "-b", "main", seed)
+	gitRepo(t, seed, "config", "user.name", "Author")
+	gitRepo(t, seed, "config", "user.email", "author@test.com")
+
+	writeFile(t, filepath.Join(seed, "README"), "base\n")
+	gitRepo(t, seed, "add", ".")
+	gitRepo(t, seed, "commit", "-m", "Initial commit")
+
+	for _, branch := range branches {
+		gitRepo(t, seed, "checkout", "-b", branch, "main")
+		writeFile(t, filepath.Join(seed, "VERSION"), branch+"\n")
+		gitRepo(t, seed, "add", ".")
+		gitRepo(t, seed, "commit", "-m", "Release "+branch)
+	}
+
+	gitRepo(t, seed, "checkout", "-b", "feature", "main")
+	writeFile(t, filepath.Join(seed, "fix.txt"), "fix\n")
+	gitRepo(t, seed, "add", ".")
+	gitRepo(t, seed, "commit", "-m", "Fix things")
+	writeFile(t, filepath.Join(seed, "docs.txt"), "docs\n")
+	gitRepo(t, seed, "add", ".")
+	gitRepo(t, seed, "commit", "-m", "Document fix")
+	repos.prCommits = strings.Fields(gitRepo(t, seed, "rev-list", "--reverse", "main..feature"))
+
+	gitRepo(t, seed, "checkout", "main")
+	// Move main forward so that rebased commits differ from the PR ones
+	writeFile(t, filepath.Join(seed, "NEWS"), "news\n")
+	gitRepo(t, seed, "add", ".")
+	gitRepo(t, seed, "commit", "-m", "Update news")
+
+	switch method {
+	case MergeMethodMerge:
+		gitRepo(t, seed, "merge", "--no-ff", "-m", "Merge pull request #1", "feature")
+	case MergeMethodSquash:
+		gitRepo(t, seed, "merge", "--squash", "feature")
+		gitRepo(t, seed, "commit", "-m", "Fix things (#1)")
+	case MergeMethodRebase:
+		gitRepo(t, seed, "cherry-pick", "main..feature")
+	}
+	repos.mergeCommit = gitRepo(t, seed, "rev-parse", "HEAD")
+
+	gitRepo(t, seed, "remote", "add", "origin", repos.origin)
+	gitRepo(t, seed, "push", "origin", "--all")
+	gitRepo(t, root, "clone", repos.origin, repos.clone)
+
+	return repos
+}
+
+// newRealGitHubClient returns a mock GitHub client answering PR and commit
+// queries from the origin repository, for a merged PR #1.
+func newRealGitHubClient(t *testing.T, repos *realRepos) *mockGitHubClient {
+	return &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{
+				Number:         intPtr(1),
+				Merged:         boolPtr(true),
+				MergeCommitSHA: stringPtr(repos.mergeCommit),
+			}, nil
+		},
+		getCommit: func(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+			return realCommit(t, repos.origin, sha), nil
+		},
+		listBranches: func(ctx context.Context, owner, repo string) ([]string, error) {
+			return strings.Fields(gitRepo(t, repos.origin, "for-each-ref", "--format=%(refname:short)", "refs/heads")), nil
+		},
+		listPRCommits: func(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+			var commits []*github.RepositoryCommit
+			for _, sha := range repos.prCommits {
+				commits = append(commits, &github.RepositoryCommit{SHA: stringPtr(sha), Commit: realCommit(t, repos.origin, sha)})
+			}
+			return commits, nil
+		},
+		compareCommits: func(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+			var commits []*github.RepositoryCommit
+			for _, sha := range strings.Fields(gitRepo(t, repos.origin, "rev-list", "--reverse", base+".."+head)) {
+				commit := realCommit(t, repos.origin, sha)
+				commits = append(commits, &github.RepositoryCommit{SHA: commit.SHA, Commit: commit, Parents: commit.Parents})
+			}
+			return commits, nil
+		},
+		createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+			return &github.PullRequest{Number: intPtr(2)}, nil
+		},
+	}
+}
+
+// realCommit reads a commit from repo the way the Git Data API returns it
+func realCommit(t *testing.T, repo, sha string) *github.Commit {
+	t.Helper()
+	date, err := time.Parse(time.RFC3339, gitRepo(t, repo, "log", "-1", "--format=%aI", sha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := &github.Commit{
+		SHA:     stringPtr(gitRepo(t, repo, "rev-parse", sha)),
+		Message: stringPtr(gitRepo(t, repo, "log", "-1", "--format=%B", sha)),
+		Tree:    &github.Tree{SHA: stringPtr(gitRepo(t, repo, "rev-parse", sha+"^{tree}"))},
+		Author: &github.CommitAuthor{
+			Name:  stringPtr(gitRepo(t, repo, "log", "-1", "--format=%an", sha)),
+			Email: stringPtr(gitRepo(t, repo, "log", "-1", "--format=%ae", sha)),
+			Date:  &github.Timestamp{Time: date},
+		},
+	}
+	for _, parent := range strings.Fields(gitRepo(t, repo, "log", "-1", "--format=%P", sha)) {
+		commit.Parents = append(commit.Parents, &github.Commit{SHA: stringPtr(parent)})
+	}
+	return commit
+}
+
+func TestProcessBranches_ParallelRealGit(t *testing.T) {
+	branches := []string{"release-v1.0", "release-v1.1", "release-v2.0"}
+	repos := setupRealRepos(t, branches, MergeMethodMerge)
+	clone, origin := repos.clone, repos.origin
+	mockGH := newRealGitHubClient(t, repos)
+
+	service := NewService(mockGH, &CommandGitRunner{Dir: clone})
+
+	cfg := &Config{
+		PRNumber:     1,
+		Branches:     branches,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+	}
+
+	results := service.ProcessBranches(context.Background(), cfg)
+
+	for _, result := range results {
+		if !result.Success {
+			t.Fatalf("Cherry-pick to %s failed: %s", result.Branch, result.ErrorMessage)
+		}
+	}
+
+	for _, branch := range branches {
+		cherryPickBranch := "cherry-pick-1-to-" + branch
+
+		// The pick must sit directly on top of its own target branch
+		parent := gitRepo(t, origin, "rev-parse", cherryPickBranch+"^")
+		if tip := gitRepo(t, origin, "rev-parse", branch); parent != tip {
+			t.Errorf("%s: expected parent %s, got %s", cherryPickBranch, tip, parent)
+		}
+
+		if version := gitRepo(t, origin, "show", cherryPickBranch+":VERSION"); version != branch {
+			t.Errorf("%s: expected VERSION %q, got %q", cherryPickBranch, branch, version)
+		}
+
+		if fix := gitRepo(t, origin, "show", cherryPickBranch+":fix.txt"); fix != "fix" {
+			t.Errorf("%s: expected fix.txt to be cherry-picked, got %q", cherryPickBranch, fix)
+		}
+
+		if committer := gitRepo(t, origin, "log", "-1", "--format=%cn <%ce>", cherryPickBranch); committer != "Test Bot <bot@test.com>" {
+			t.Errorf("%s: unexpected committer %q", cherryPickBranch, committer)
+		}
+	}
+
+	// The main checkout must be untouched and all worktrees cleaned up
+	if head := gitRepo(t, clone, "rev-parse", "--abbrev-ref", "HEAD"); head != "main" {
+		t.Errorf("Expected clone to stay on main, got %s", head)
+	}
+
+	if status := gitRepo(t, clone, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected clean clone, got:\n%s", status)
+	}
+
+	if worktrees := gitRepo(t, clone, "worktree", "list", "--porcelain"); strings.Count(worktrees, "worktree ") != 1 {
+		t.Errorf("Expected worktrees to be removed, got:\n%s", worktrees)
+	}
+
+	if local := gitRepo(t, clone, "branch", "--list", "cherry-pick-*"); local != "" {
+		t.Errorf("Expected local cherry-pick branches to be removed, got:\n%s", local)
+	}
+}
+
+func TestProcessBranch_ProvenanceRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodRebase)
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:     1,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		Signoff:      true,
+		Trailers:     []string{"Backport-Of: #1"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+	}
+
+	branch := "cherry-pick-1-to-release-v1.0"
+	picked := strings.Fields(gitRepo(t, repos.origin, "rev-list", "--reverse", "release-v1.0.."+branch))
+	source := strings.Fields(gitRepo(t, repos.origin, "rev-list", "--reverse", repos.mergeCommit+"~2.."+repos.mergeCommit))
+	if len(picked) != 2 || len(source) != 2 {
+		t.Fatalf("Expected 2 picked commits from 2 source commits, got %v from %v", picked, source)
+	}
+
+	for i, commit := range picked {
+		if author := gitRepo(t, repos.origin, "log", "-1", "--format=%an <%ae>", commit); author != "Author <author@test.com>" {
+			t.Errorf("%s: expected the original author, got %q", commit, author)
+		}
+		if committer := gitRepo(t, repos.origin, "log", "-1", "--format=%cn <%ce>", commit); committer != "Test Bot <bot@test.com>" {
+			t.Errorf("%s: expected the bot as committer, got %q", commit, committer)
+		}
+
+		message := gitRepo(t, repos.origin, "log", "-1", "--format=%B", commit)
+		for _, want := range []string{
+			"(cherry picked from commit " + source[i] + ")",
+			"Signed-off-by: Test Bot <bot@test.com>",
+			"Backport-Of: #1",
+		} {
+			if !strings.Contains(message, want) {
+				t.Errorf("%s: expected %q in the message, got:\n%s", commit, want, message)
+			}
+		}
+	}
+}
+
+func TestProcessBranch_ErrorClasses(t *testing.T) {
+	tests := []struct {
+		name     string
+		merged   bool
+		gitFail  func(cmd GitCommand) (GitOutput, error)
+		createPR error
+		want     error
+	}{
+		{
+			name:   "not merged",
+			merged: false,
+			want:   ErrNotMerged,
+		},
+		{
+			name:   "branch not found",
+			merged: true,
+			gitFail: func(cmd GitCommand) (GitOutput, error) {
+				if cmd.Args[0] == "fetch" {
+					stderr := "fatal: couldn't find remote ref refs/heads/nonexistent"
+					return GitOutput{Stderr: stderr}, &GitError{Args: cmd.Args, Err: errors.New("exit status 128"), Stderr: stderr}
+				}
+				return GitOutput{}, nil
+			},
+			want: ErrBranchNotFound,
+		},
+		{
+			name:   "conflict",
+			merged: true,
+			gitFail: func(cmd GitCommand) (GitOutput, error) {
+				args := gitSubcommand(cmd)
+				if args[0] == "cherry-pick" && args[1] != "--abort" {
+					return GitOutput{}, errors.New("exit status 1")
+				}
+				if args[0] == "diff" {
+					return GitOutput{Stdout: "main.go\n"}, nil
+				}
+				return GitOutput{}, nil
+			},
+			want: ErrConflict,
+		},
+		{
+			name:   "push rejected",
+			merged: true,
+			gitFail: func(cmd GitCommand) (GitOutput, error) {
+				if cmd.Args[0] == "push" {
+					return GitOutput{}, errors.New("! [remote rejected] (protected branch hook declined)")
+				}
+				return GitOutput{}, nil
+			},
+			want: ErrPushRejected,
+		},
+		{
+			name:     "PR creation failed",
+			merged:   true,
+			createPR: errors.New("422 Validation Failed"),
+			want:     ErrPRCreationFailed,
+		},
+	}
+
+	classes := []error{ErrNotMerged, ErrBranchNotFound, ErrConflict, ErrPushRejected, ErrPRCreationFailed}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGH := &mockGitHubClient{
+				getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+					return &github.PullRequest{
+						Merged:         boolPtr(tt.merged),
+						MergeCommitSHA: stringPtr("abc123"),
+					}, nil
+				},
+				createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+					if tt.createPR != nil {
+						return nil, tt.createPR
+					}
+					return &github.PullRequest{Number: intPtr(1)}, nil
+				},
+			}
+
+			service := NewService(mockGH, &mockGitRunner{runFunc: tt.gitFail})
+			cfg := &Config{PRNumber: 123, RepoOwner: "owner", RepoName: "repo"}
+
+			result := service.ProcessBranch(context.Background(), cfg, "release")
+
+			if !result.Failed() {
+				t.Fatal("Expected a failure")
+			}
+
+			for _, class := range classes {
+				if got, want := errors.Is(result.Error, class), class == tt.want; got != want {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", result.Error, class, got, want)
+				}
+			}
+		})
+	}
+}
@@ -2,22 +2,104 @@ package cherrypick
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/google/go-github/v66/github"
 )
 
-// CommentPoster handles posting comments to GitHub
+// Notifier reports the lifecycle of a cherry-pick command back to the user
+type Notifier interface {
+	ProgressReporter
+	AddReaction(ctx context.Context, commentID int64, reaction string) (int64, error)
+	RemoveReaction(ctx context.Context, commentID, reactionID int64) error
+	PostError(ctx context.Context, message string) error
+	StartProgress(ctx context.Context, branches []string) error
+	PostResults(ctx context.Context, results []*Result) error
+	// PostDeferred reports the cherry-picks waiting for the PR to be merged
+	PostDeferred(ctx context.Context, branches []string) error
+}
+
+// IssueClient defines the interface for GitHub issue comment operations
+type IssueClient interface {
+	CreateComment(ctx context.Context, owner, repo string, number int, body string) (int64, error)
+	EditComment(ctx context.Context, owner, repo string, commentID int64, body string) error
+	ListComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error)
+	CreateReaction(ctx context.Context, owner, repo string, commentID int64, reaction string) (int64, error)
+	DeleteReaction(ctx context.Context, owner, repo string, commentID, reactionID int64) error
+	// CurrentUser returns the login of the authenticated user
+	CurrentUser(ctx context.Context) (string, error)
+}
+
+func (c *DefaultGitHubClient) CreateComment(ctx context.Context, owner, repo string, number int, body string) (int64, error) {
+	comment, _, err := c.client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
+		Body: &body,
+	})
+	return comment.GetID(), err
+}
+
+func (c *DefaultGitHubClient) EditComment(ctx context.Context, owner, repo string, commentID int64, body string) error {
+	_, _, err := c.client.Issues.EditComment(ctx, owner, repo, commentID, &github.IssueComment{
+		Body: &body,
+	})
+	return err
+}
+
+// ListComments returns all the comments of an issue or PR, oldest first
+func (c *DefaultGitHubClient) ListComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var all []*github.IssueComment
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, comments...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (c *DefaultGitHubClient) CurrentUser(ctx context.Context) (string, error) {
+	user, _, err := c.client.Users.Get(ctx, "")
+	return user.GetLogin(), err
+}
+
+func (c *DefaultGitHubClient) CreateReaction(ctx context.Context, owner, repo string, commentID int64, reaction string) (int64, error) {
+	r, _, err := c.client.Reactions.CreateIssueCommentReaction(ctx, owner, repo, commentID, reaction)
+	return r.GetID(), err
+}
+
+func (c *DefaultGitHubClient) DeleteReaction(ctx context.Context, owner, repo string, commentID, reactionID int64) error {
+	_, err := c.client.Reactions.DeleteIssueCommentReaction(ctx, owner, repo, commentID, reactionID)
+	return err
+}
+
+// CommentPoster implements Notifier with comments and reactions on the
+// triggering PR
 type CommentPoster struct {
-	client      *github.Client
+	client      IssueClient
 	repoOwner   string
 	repoName    string
 	issueNumber int
+
+	// mu guards the summary comment, which is edited concurrently while
+	// branches report their progress
+	mu        sync.Mutex
+	summaryID int64
+	branches  []string
+	events    map[string]Event
 }
 
 // NewCommentPoster creates a new comment poster
-func NewCommentPoster(client *github.Client, repoOwner, repoName string, issueNumber int) *CommentPoster {
+func NewCommentPoster(client IssueClient, repoOwner, repoName string, issueNumber int) *CommentPoster {
 	return &CommentPoster{
 		client:      client,
 		repoOwner:   repoOwner,
@@ -26,15 +108,27 @@ func NewCommentPoster(client *github.Client, repoOwner, repoName string, issueNu
 	}
 }
 
-// AddReaction adds a reaction to a comment
-func (cp *CommentPoster) AddReaction(ctx context.Context, commentID int64, reaction string) error {
+// AddReaction adds a reaction to a comment and returns the reaction ID
+func (cp *CommentPoster) AddReaction(ctx context.Context, commentID int64, reaction string) (int64, error) {
 	if commentID == 0 {
-		return nil
+		return 0, nil
 	}
 
-	_, _, err := cp.client.Reactions.CreateIssueCommentReaction(ctx, cp.repoOwner, cp.repoName, commentID, reaction)
+	reactionID, err := cp.client.CreateReaction(ctx, cp.repoOwner, cp.repoName, commentID, reaction)
 	if err != nil {
-		return fmt.Errorf("failed to add reaction: %w", err)
+		return 0, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	return reactionID, nil
+}
+
+// RemoveReaction removes a reaction previously added with AddReaction
+func (cp *CommentPoster) RemoveReaction(ctx context.Context, commentID, reactionID int64) error {
+	if commentID == 0 || reactionID == 0 {
+		return nil
+	}
+
+	if err := cp.client.DeleteReaction(ctx, cp.repoOwner, cp.repoName, commentID, reactionID); err != nil {
+		return fmt.Errorf("failed to remove reaction: %w", err)
 	}
 	return nil
 }
@@ -46,26 +140,208 @@ func (cp *CommentPoster) PostError(ctx context.Context, message string) error {
 	}
 
 	body := fmt.Sprintf("❌ **Cherry-pick failed**: %s\n\n"+
-		"**Usage**: `/cherry-pick <target-branch> [<target-branch2> ...]`\n"+
+		"**Usage**: `/cherry-pick <target-branch|glob|alias> [<target-branch2> ...] [commits=<sha>[,<sha>|<sha>..<sha>]] [strategy-option=<option>[,<option>]]`\n"+
 		"**Examples**:\n"+
 		"- `/cherry-pick release-v1.0`\n"+
-		"- `/cherry-pick release-v1.0 release-v1.1 release-v2.0`\n", message)
+		"- `/cherry-pick release-v1.0 release-v1.1 release-v2.0`\n"+
+		"- `/cherry-pick release-v1.*` (all the matching branches)\n"+
+		"- `/cherry-pick release-v1.0 commits=abc1234`\n"+
+		"- `/cherry-pick release-v1.0 strategy-option=theirs`\n", message)
+
+	_, err := cp.postComment(ctx, body)
+	return err
+}
 
-	return cp.postComment(ctx, body)
+// summaryMarker is a hidden marker identifying the summary comment, so that
+// later runs edit it instead of posting a new one
+const summaryMarker = "<!-- cherry-pick-summary -->"
+
+// PostResults posts a single summary comment for all branches, or updates
+// the one left by a previous run
+func (cp *CommentPoster) PostResults(ctx context.Context, results []*Result) error {
+	if cp.issueNumber == 0 {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := cp.upsertSummary(ctx, cp.formatSummary(results)); err != nil {
+		return fmt.Errorf("failed to post summary comment: %w", err)
+	}
+	return nil
+}
+
+// PostDeferred posts the summary comment listing the cherry-picks recorded on
+// an open PR. The summary of their run replaces it once the PR is merged.
+func (cp *CommentPoster) PostDeferred(ctx context.Context, branches []string) error {
+	if cp.issueNumber == 0 {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := cp.upsertSummary(ctx, formatDeferred(branches)); err != nil {
+		return fmt.Errorf("failed to post summary comment: %w", err)
+	}
+	return nil
+}
+
+// formatDeferred renders the summary of the cherry-picks waiting for the merge
+func formatDeferred(branches []string) string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n")
+	b.WriteString("### 🍒 Cherry-pick summary\n\n")
+	b.WriteString("⏳ This PR is not merged yet: it will be cherry-picked once it is merged.\n\n")
+	b.WriteString("| Branch | Status |\n")
+	b.WriteString("| --- | --- |\n")
+	for _, branch := range branches {
+		fmt.Fprintf(&b, "| `%s` | ⏳ Waiting for merge |\n", branch)
+	}
+	fmt.Fprintf(&b, "\nTo cancel a cherry-pick, remove its `%s<branch>` label.\n", DeferredLabelPrefix)
+	return b.String()
+}
+
+// StartProgress posts the summary comment in its in-progress state, with
+// every branch pending. Events reported afterwards through ReportProgress
+// edit it until PostResults replaces it with the final summary.
+func (cp *CommentPoster) StartProgress(ctx context.Context, branches []string) error {
+	if cp.issueNumber == 0 {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.branches = append([]string(nil), branches...)
+	cp.events = map[string]Event{}
+	return cp.upsertSummary(ctx, cp.formatProgress())
 }
 
-// PostResults posts result comments for each branch
-func (cp *CommentPoster) PostResults(ctx context.Context, results []*Result) {
+// ReportProgress implements ProgressReporter by editing the summary comment.
+// Events are ignored until StartProgress is called.
+func (cp *CommentPoster) ReportProgress(ctx context.Context, event Event) {
 	if cp.issueNumber == 0 {
 		return
 	}
 
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.events == nil {
+		return
+	}
+
+	if _, ok := cp.events[event.Branch]; !ok && !slices.Contains(cp.branches, event.Branch) {
+		cp.branches = append(cp.branches, event.Branch)
+	}
+	cp.events[event.Branch] = event
+
+	if err := cp.upsertSummary(ctx, cp.formatProgress()); err != nil {
+		log.Printf("Error updating progress comment for %s: %v", event.Branch, err)
+	}
+}
+
+// formatProgress renders the in-progress summary, one row per branch
+func (cp *CommentPoster) formatProgress() string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n")
+	b.WriteString("### ⏳ Cherry-pick in progress\n\n")
+	b.WriteString("| Branch | Status |\n")
+	b.WriteString("| --- | --- |\n")
+
+	for _, branch := range cp.branches {
+		fmt.Fprintf(&b, "| `%s` | %s |\n", branch, stageStatus(cp.events[branch]))
+	}
+
+	return b.String()
+}
+
+// stageStatus is the status column of the in-progress summary
+func stageStatus(event Event) string {
+	switch event.Stage {
+	case StageFetching:
+		return "📥 Fetching target branch"
+	case StagePicking:
+		return "🍒 Cherry-picking"
+	case StagePushing:
+		return "📤 Pushing"
+	case StagePROpened:
+		return fmt.Sprintf("🔀 Opened #%d", event.PR.GetNumber())
+	case StageDone:
+		return resultStatus(event.Result)
+	default:
+		return "⏳ Pending"
+	}
+}
+
+// formatSummary renders a table with one row per branch, followed by the
+// details of the branches needing attention
+func (cp *CommentPoster) formatSummary(results []*Result) string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n")
+	b.WriteString("### 🍒 Cherry-pick summary\n\n")
+	b.WriteString("| Branch | Status | PR | Error |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+
 	for _, result := range results {
-		body := cp.formatResult(result)
-		if err := cp.postComment(ctx, body); err != nil {
-			log.Printf("Error posting result comment for %s: %v", result.Branch, err)
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n",
+			result.Branch, resultStatus(result), resultPR(result), tableCell(result.ErrorMessage))
+	}
+
+	for _, result := range results {
+		if !result.Failed() && (result.StalePR == nil || result.NewPR != nil) && len(result.Warnings) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n<details>\n<summary>Details for <code>%s</code></summary>\n\n%s\n</details>\n",
+			result.Branch, cp.formatResult(result))
+	}
+
+	return b.String()
+}
+
+// resultStatus is the status column of the summary table
+func resultStatus(result *Result) string {
+	switch {
+	case result.MergedPR != nil:
+		return "ℹ️ Already backported"
+	case result.ExistingPR != nil:
+		return "ℹ️ Already exists"
+	case result.AlreadyApplied:
+		return "ℹ️ Already applied"
+	case result.Success && result.StalePR != nil:
+		return "✅ Recreated"
+	case result.Success && result.AutoMerge != "":
+		return "✅ Created (auto-merge)"
+	case result.Success:
+		return "✅ Created"
+	case result.StalePR != nil && result.Error == nil:
+		return "⚠️ Closed without merging"
+	case result.DraftPR != nil:
+		return "⚠️ Conflicts (draft)"
+	default:
+		return "❌ Failed"
+	}
+}
+
+// resultPR is the PR column of the summary table
+func resultPR(result *Result) string {
+	for _, pr := range []*github.PullRequest{result.NewPR, result.DraftPR, result.ExistingPR, result.MergedPR, result.StalePR} {
+		if pr != nil {
+			return fmt.Sprintf("#%d", pr.GetNumber())
 		}
 	}
+	return ""
+}
+
+// tableCell keeps the first line of s and escapes it for a markdown table
+func tableCell(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	if runes := []rune(line); len(runes) > 120 {
+		line = string(runes[:117]) + "..."
+	}
+	return strings.ReplaceAll(line, "|", "\\|")
 }
 
 func (cp *CommentPoster) formatResult(result *Result) string {
@@ -76,27 +352,196 @@ func (cp *CommentPoster) formatResult(result *Result) string {
 			result.Branch, result.ExistingPR.GetNumber(), result.ExistingPR.GetHTMLURL())
 	}
 
+	if result.MergedPR != nil {
+		return fmt.Sprintf("ℹ️ **Cherry-pick to `%s` already backported!**\n\n"+
+			"This change was already cherry-picked and merged in #%d\n\n"+
+			"**PR**: %s\n",
+			result.Branch, result.MergedPR.GetNumber(), result.MergedPR.GetHTMLURL())
+	}
+
+	if result.AlreadyApplied {
+		return fmt.Sprintf("ℹ️ **`%s` already has this change!**\n\n"+
+			"The change is already applied on `%s`, for instance because it was backported by hand, "+
+			"so no pull request was opened.\n",
+			result.Branch, result.Branch)
+	}
+
+	if result.StalePR != nil && result.NewPR == nil && result.Error == nil {
+		return fmt.Sprintf("⚠️ **Cherry-pick to `%s` was closed without merging!**\n\n"+
+			"A previous pull request for this cherry-pick was closed without being merged: #%d\n\n"+
+			"To recreate it, run `/cherry-pick %s recreate=true`. "+
+			"This resets the existing cherry-pick branch.\n",
+			result.Branch, result.StalePR.GetNumber(), result.Branch)
+	}
+
 	if result.Success && result.NewPR != nil {
+		replaces := ""
+		if result.StalePR != nil {
+			replaces = fmt.Sprintf("It replaces #%d, which was closed without merging.\n\n", result.StalePR.GetNumber())
+		}
 		return fmt.Sprintf("✅ **Cherry-pick to `%s` successful!**\n\n"+
 			"A new pull request has been created to cherry-pick this change to `%s`.\n\n"+
+			"%s"+
 			"**PR**: %s\n\n"+
-			"Please review and merge the cherry-pick PR.\n",
-			result.Branch, result.Branch, result.NewPR.GetHTMLURL())
+			"%s"+
+			"%s",
+			result.Branch, result.Branch, replaces, result.NewPR.GetHTMLURL(), formatWarnings(result.Warnings), mergeStep(result))
+	}
+
+	if result.DraftPR != nil {
+		return fmt.Sprintf("⚠️ **Cherry-pick to `%s` has conflicts!**\n\n"+
+			"The change conflicts with `%s`. It was committed with its conflict markers "+
+			"and a draft pull request was opened for you to finish it.\n\n"+
+			"**Draft PR**: %s\n\n"+
+			"%s"+
+			"%s"+
+			"**Next steps:**\n"+
+			"- Check out the draft PR (`gh pr checkout %d`), fix the conflicting files and push\n"+
+			"- Mark the draft PR as ready for review\n",
+			result.Branch, result.Branch, result.DraftPR.GetHTMLURL(), formatConflicts(result.Conflicts),
+			formatWarnings(result.Warnings), result.DraftPR.GetNumber())
 	}
 
 	return fmt.Sprintf("❌ **Cherry-pick to `%s` failed!**\n\n"+
 		"The automatic cherry-pick to `%s` failed.\n\n"+
 		"**Error:**\n"+
 		"```\n%s\n```\n\n"+
-		"**Next steps:**\n"+
-		"- If the PR is not merged, merge it first and try again\n"+
-		"- If there are conflicts, you'll need to manually cherry-pick this PR\n",
-		result.Branch, result.Branch, result.ErrorMessage)
+		"%s"+
+		"**Next steps:**\n%s",
+		result.Branch, result.Branch, result.ErrorMessage, formatConflicts(result.Conflicts), nextSteps(result))
 }
 
-func (cp *CommentPoster) postComment(ctx context.Context, body string) error {
-	_, _, err := cp.client.Issues.CreateComment(ctx, cp.repoOwner, cp.repoName, cp.issueNumber, &github.IssueComment{
-		Body: &body,
-	})
+// formatConflicts lists the conflicting files, each with its hunks in a
+// collapsible section
+func formatConflicts(files []ConflictFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Conflicting files (%d):**\n\n", len(files))
+	for _, file := range files {
+		fmt.Fprintf(&b, "<details>\n<summary><code>%s</code> (%s)</summary>\n\n", file.Path, conflictCount(file.Hunks))
+		if file.Diff != "" {
+			fmt.Fprintf(&b, "```diff\n%s\n```\n", file.Diff)
+		}
+		b.WriteString("</details>\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// mergeStep tells how the cherry-pick PR of a successful result gets merged
+func mergeStep(result *Result) string {
+	if result.AutoMerge != "" {
+		return fmt.Sprintf("Auto-merge (%s) is enabled: the cherry-pick PR will be merged once its checks pass.\n", result.AutoMerge)
+	}
+	return "Please review and merge the cherry-pick PR.\n"
+}
+
+// formatWarnings lists the non-fatal failures of a cherry-pick
+func formatWarnings(warnings []string) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("**Warnings:**\n")
+	for _, warning := range warnings {
+		fmt.Fprintf(&b, "- %s\n", warning)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// nextSteps suggests how to move forward depending on the failure class
+func nextSteps(result *Result) string {
+	var notFound *BranchNotFoundError
+	switch {
+	case errors.Is(result.Error, ErrNotMerged):
+		return "- Merge the PR first, then run `/cherry-pick " + result.Branch + "` again\n"
+	case errors.As(result.Error, &notFound) && len(notFound.Suggestions) > 0:
+		steps := "- `" + notFound.Branch + "` does not exist, did you mean one of these?\n"
+		for _, branch := range notFound.Suggestions {
+			steps += "  - `/cherry-pick " + branch + "`\n"
+		}
+		return steps
+	case errors.Is(result.Error, ErrBranchNotFound):
+		return "- Check the spelling of `" + result.Branch + "`: the branch must exist in this repository\n"
+	case errors.Is(result.Error, ErrBranchNotAllowed):
+		return "- Cherry-picks to `" + result.Branch + "` are not allowed by `" + RepoConfigPath + "`\n" +
+			"- Pick one of the allowed branches, or update the `branches` of the configuration\n"
+	case errors.Is(result.Error, ErrConflict):
+		return "- The change conflicts with `" + result.Branch + "`, you'll need to manually cherry-pick this PR\n" +
+			"- Resolve the conflicts locally and open a PR against `" + result.Branch + "`\n" +
+			"- Or run `/cherry-pick " + result.Branch + " draft=true` to get a draft PR with the conflict markers committed\n"
+	case errors.Is(result.Error, ErrPushRejected):
+		return "- Check that the bot is allowed to push `cherry-pick-*` branches (permissions, branch protection rules)\n" +
+			"- Then run `/cherry-pick " + result.Branch + "` again\n"
+	case errors.Is(result.Error, ErrPRCreationFailed):
+		return "- The cherry-pick branch was pushed: open a pull request from it against `" + result.Branch + "` manually\n"
+	default:
+		return "- If the PR is not merged, merge it first and try again\n" +
+			"- If there are conflicts, you'll need to manually cherry-pick this PR\n"
+	}
+}
+
+// upsertSummary edits the summary comment, looking up the one left by a
+// previous run the first time, or posts a new one. cp.mu must be held.
+func (cp *CommentPoster) upsertSummary(ctx context.Context, body string) error {
+	if cp.summaryID == 0 {
+		commentID, err := cp.findSummaryComment(ctx)
+		if err != nil {
+			return err
+		}
+		cp.summaryID = commentID
+	}
+
+	if cp.summaryID == 0 {
+		commentID, err := cp.postComment(ctx, body)
+		cp.summaryID = commentID
+		return err
+	}
+
+	err := cp.client.EditComment(ctx, cp.repoOwner, cp.repoName, cp.summaryID, body)
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil &&
+		(errResp.Response.StatusCode == http.StatusForbidden || errResp.Response.StatusCode == http.StatusNotFound) {
+		// The summary was deleted, or cannot be edited anymore
+		log.Printf("Warning: failed to edit summary comment %d, posting a new one: %v", cp.summaryID, err)
+		commentID, err := cp.postComment(ctx, body)
+		cp.summaryID = commentID
+		return err
+	}
 	return err
 }
+
+// findSummaryComment returns the ID of the latest comment carrying the
+// summary marker written by the authenticated user, or 0 if there is none.
+// Anyone can copy the marker in their own comments, which must not be
+// edited.
+func (cp *CommentPoster) findSummaryComment(ctx context.Context) (int64, error) {
+	login, err := cp.client.CurrentUser(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to look up the authenticated user, posting a new summary: %v", err)
+		return 0, nil
+	}
+
+	comments, err := cp.client.ListComments(ctx, cp.repoOwner, cp.repoName, cp.issueNumber)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	var found int64
+	for _, comment := range comments {
+		if strings.Contains(comment.GetBody(), summaryMarker) && strings.EqualFold(comment.GetUser().GetLogin(), login) {
+			found = comment.GetID()
+		}
+	}
+	return found, nil
+}
+
+// postComment posts a new comment and returns its ID
+func (cp *CommentPoster) postComment(ctx context.Context, body string) (int64, error) {
+	return cp.client.CreateComment(ctx, cp.repoOwner, cp.repoName, cp.issueNumber, body)
+}
@@ -1,8 +1,15 @@
 package cherrypick
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/google/go-github/v66/github"
 )
@@ -92,3 +99,687 @@ func TestFormatResult_Failure(t *testing.T) {
 		t.Error("Expected 'Next steps' in comment body")
 	}
 }
+
+func TestFormatResult_Conflicts(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:       "release-1.0",
+		Error:        &ConflictError{Err: errors.New("exit status 1")},
+		ErrorMessage: "cherry-pick failed due to conflicts or other errors: exit status 1",
+		Conflicts: []ConflictFile{
+			{Path: "main.go", Diff: "@@@ -1,1 -1,1 +1,5 @@@\n++<<<<<<< HEAD", Hunks: 2},
+			{Path: "README.md", Hunks: 1},
+		},
+	}
+
+	body := poster.formatResult(result)
+
+	for _, want := range []string{
+		"**Conflicting files (2):**",
+		"<details>\n<summary><code>main.go</code> (2 conflicts)</summary>",
+		"```diff\n@@@ -1,1 -1,1 +1,5 @@@\n++<<<<<<< HEAD\n```",
+		"<summary><code>README.md</code> (1 conflict)</summary>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in comment body, got:\n%s", want, body)
+		}
+	}
+
+	if strings.Index(body, "Conflicting files") > strings.Index(body, "Next steps") {
+		t.Error("Expected conflicting files before the next steps")
+	}
+}
+
+func TestFormatResult_DraftPR(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:       "release-1.0",
+		DraftPR:      &github.PullRequest{Number: intPtr(42), HTMLURL: stringPtr("https://github.com/owner/repo/pull/42")},
+		Error:        &ConflictError{Err: errors.New("cherry-pick has conflicts, committed to draft PR #42")},
+		ErrorMessage: "cherry-pick has conflicts, committed to draft PR #42 (1 conflicting files)",
+		Conflicts:    []ConflictFile{{Path: "main.go", Hunks: 1}},
+	}
+
+	body := poster.formatResult(result)
+
+	for _, want := range []string{
+		"has conflicts",
+		"**Draft PR**: https://github.com/owner/repo/pull/42",
+		"<summary><code>main.go</code> (1 conflict)</summary>",
+		"gh pr checkout 42",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in comment body, got:\n%s", want, body)
+		}
+	}
+
+	if status := resultStatus(result); status != "⚠️ Conflicts (draft)" {
+		t.Errorf("Unexpected status %q", status)
+	}
+	if pr := resultPR(result); pr != "#42" {
+		t.Errorf("Expected the draft PR in the summary, got %q", pr)
+	}
+}
+
+func TestFormatResult_Warnings(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:  "release-1.0",
+		Success: true,
+		NewPR: &github.PullRequest{
+			Number:  intPtr(789),
+			HTMLURL: stringPtr("https://github.com/owner/repo/pull/789"),
+		},
+		Warnings: []string{`failed to set milestone "v1.0.x" on #789: no open milestone "v1.0.x"`},
+	}
+
+	body := poster.formatResult(result)
+	if !strings.Contains(body, "successful") {
+		t.Error("Expected 'successful' in comment body")
+	}
+	if !strings.Contains(body, "**Warnings:**\n- failed to set milestone") {
+		t.Errorf("Expected the warnings in comment body, got:\n%s", body)
+	}
+
+	// Warnings are detailed in the summary even though the pick succeeded
+	summary := poster.formatSummary([]*Result{result})
+	if !strings.Contains(summary, "Details for <code>release-1.0</code>") {
+		t.Errorf("Expected details for the warnings in the summary, got:\n%s", summary)
+	}
+}
+
+func TestFormatResult_AutoMerge(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:    "release-1.0",
+		Success:   true,
+		NewPR:     &github.PullRequest{Number: intPtr(789), HTMLURL: stringPtr("https://github.com/owner/repo/pull/789")},
+		AutoMerge: MergeMethodSquash,
+	}
+
+	body := poster.formatResult(result)
+	if !strings.Contains(body, "Auto-merge (squash) is enabled") {
+		t.Errorf("Expected auto-merge in comment body, got:\n%s", body)
+	}
+	if strings.Contains(body, "Please review and merge") {
+		t.Errorf("Expected no request to merge by hand, got:\n%s", body)
+	}
+
+	if summary := poster.formatSummary([]*Result{result}); !strings.Contains(summary, "| `release-1.0` | ✅ Created (auto-merge) | #789 |  |") {
+		t.Errorf("Expected auto-merge in the summary, got:\n%s", summary)
+	}
+}
+
+func TestFormatResult_MergedPR(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:  "release-1.0",
+		Success: true,
+		MergedPR: &github.PullRequest{
+			Number:  intPtr(456),
+			HTMLURL: stringPtr("https://github.com/owner/repo/pull/456"),
+		},
+	}
+
+	body := poster.formatResult(result)
+
+	if !strings.Contains(body, "already backported") {
+		t.Error("Expected 'already backported' in comment body")
+	}
+
+	if !strings.Contains(body, "#456") {
+		t.Error("Expected PR number in comment body")
+	}
+}
+
+func TestFormatResult_AlreadyApplied(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:         "release-1.0",
+		Success:        true,
+		AlreadyApplied: true,
+	}
+
+	body := poster.formatResult(result)
+
+	if !strings.Contains(body, "already has this change") {
+		t.Errorf("Expected 'already has this change' in comment body, got:\n%s", body)
+	}
+	if result.Failed() {
+		t.Error("Expected an already applied change not to be a failure")
+	}
+	if status := resultStatus(result); status != "ℹ️ Already applied" {
+		t.Errorf("Unexpected status %q", status)
+	}
+}
+
+func TestFormatResult_StalePR(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch: "release-1.0",
+		StalePR: &github.PullRequest{
+			Number: intPtr(456),
+		},
+	}
+
+	body := poster.formatResult(result)
+
+	if !strings.Contains(body, "closed without merging") {
+		t.Error("Expected 'closed without merging' in comment body")
+	}
+
+	if !strings.Contains(body, "#456") {
+		t.Error("Expected stale PR number in comment body")
+	}
+
+	if !strings.Contains(body, "recreate=true") {
+		t.Error("Expected recreate instructions in comment body")
+	}
+}
+
+func TestFormatResult_RecreatedPR(t *testing.T) {
+	poster := &CommentPoster{}
+
+	result := &Result{
+		Branch:  "release-1.0",
+		Success: true,
+		StalePR: &github.PullRequest{
+			Number: intPtr(456),
+		},
+		NewPR: &github.PullRequest{
+			Number:  intPtr(789),
+			HTMLURL: stringPtr("https://github.com/owner/repo/pull/789"),
+		},
+	}
+
+	body := poster.formatResult(result)
+
+	if !strings.Contains(body, "successful") {
+		t.Error("Expected 'successful' in comment body")
+	}
+
+	if !strings.Contains(body, "replaces #456") {
+		t.Error("Expected the stale PR to be mentioned in comment body")
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	poster := &CommentPoster{}
+
+	results := []*Result{
+		{
+			Branch:  "release-1.0",
+			Success: true,
+			NewPR:   &github.PullRequest{Number: intPtr(10)},
+		},
+		{
+			Branch:     "release-1.1",
+			Success:    true,
+			ExistingPR: &github.PullRequest{Number: intPtr(11)},
+		},
+		{
+			Branch:       "release-2.0",
+			ErrorMessage: "cherry-pick failed | conflicts\nCONFLICT (content): Merge conflict in main.go",
+		},
+	}
+
+	body := poster.formatSummary(results)
+
+	if !strings.HasPrefix(body, summaryMarker) {
+		t.Error("Expected summary to start with the hidden marker")
+	}
+
+	for _, row := range []string{
+		"| `release-1.0` | ✅ Created | #10 |  |",
+		"| `release-1.1` | ℹ️ Already exists | #11 |  |",
+		"| `release-2.0` | ❌ Failed |  | cherry-pick failed \\| conflicts |",
+	} {
+		if !strings.Contains(body, row) {
+			t.Errorf("Expected row %q in summary:\n%s", row, body)
+		}
+	}
+
+	// Only the failure gets a details section, with the full error
+	if strings.Count(body, "<details>") != 1 {
+		t.Errorf("Expected a single details section:\n%s", body)
+	}
+	if !strings.Contains(body, "Merge conflict in main.go") {
+		t.Error("Expected the full error in the details section")
+	}
+}
+
+// fakeIssueClient is an in-memory IssueClient recording what is posted
+type fakeIssueClient struct {
+	mu        sync.Mutex
+	comments  []*github.IssueComment
+	created   []string
+	edited    []editedComment
+	reactions []string
+	deleted   []int64
+	// login is the authenticated user, "bot" by default
+	login string
+	// editErr is returned by EditComment when set
+	editErr error
+	// err is returned by every call when set
+	err error
+}
+
+type editedComment struct {
+	id   int64
+	body string
+}
+
+func (f *fakeIssueClient) CreateComment(ctx context.Context, owner, repo string, number int, body string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.created = append(f.created, body)
+	return int64(100 + len(f.created)), nil
+}
+
+func (f *fakeIssueClient) EditComment(ctx context.Context, owner, repo string, commentID int64, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	if f.editErr != nil {
+		return f.editErr
+	}
+	f.edited = append(f.edited, editedComment{id: commentID, body: body})
+	return nil
+}
+
+func (f *fakeIssueClient) ListComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.comments, f.err
+}
+
+func (f *fakeIssueClient) CurrentUser(ctx context.Context) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return cmp.Or(f.login, "bot"), nil
+}
+
+func (f *fakeIssueClient) CreateReaction(ctx context.Context, owner, repo string, commentID int64, reaction string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.reactions = append(f.reactions, reaction)
+	return int64(len(f.reactions)), nil
+}
+
+func (f *fakeIssueClient) DeleteReaction(ctx context.Context, owner, repo string, commentID, reactionID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.deleted = append(f.deleted, reactionID)
+	return nil
+}
+
+func TestPostDeferred(t *testing.T) {
+	fake := &fakeIssueClient{}
+	poster := NewCommentPoster(fake, "owner", "repo", 7)
+
+	if err := poster.PostDeferred(context.Background(), []string{"release-1.0", "release-2.0"}); err != nil {
+		t.Fatalf("PostDeferred() error = %v", err)
+	}
+	if len(fake.created) != 1 {
+		t.Fatalf("Expected a single comment, got %d", len(fake.created))
+	}
+
+	body := fake.created[0]
+	if !strings.HasPrefix(body, summaryMarker) {
+		t.Error("Expected the summary marker, so that the results replace the comment")
+	}
+	for _, want := range []string{
+		"not merged yet",
+		"| `release-1.0` | ⏳ Waiting for merge |",
+		"| `release-2.0` | ⏳ Waiting for merge |",
+		"remove its `cherry-pick/<branch>` label",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in comment body:\n%s", want, body)
+		}
+	}
+}
+
+func TestTableCell(t *testing.T) {
+	long := strings.Repeat("é", 130)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Fix things\nmore", want: "Fix things"},
+		{in: "a | b", want: "a \\| b"},
+		{in: long, want: strings.Repeat("é", 117) + "..."},
+		{in: strings.Repeat("é", 120), want: strings.Repeat("é", 120)},
+	}
+
+	for _, tt := range tests {
+		got := tableCell(tt.in)
+		if got != tt.want {
+			t.Errorf("tableCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("tableCell(%q) = %q, which is not valid UTF-8", tt.in, got)
+		}
+	}
+}
+
+func TestPostResults_EditsExistingSummary(t *testing.T) {
+	bot := &github.User{Login: stringPtr("bot")}
+
+	tests := []struct {
+		name     string
+		comments []*github.IssueComment
+		wantEdit bool
+	}{
+		{
+			name:     "no summary yet",
+			comments: []*github.IssueComment{{ID: int64Ptr(1), Body: stringPtr("LGTM")}},
+			wantEdit: false,
+		},
+		{
+			name: "existing summary",
+			comments: []*github.IssueComment{
+				{ID: int64Ptr(1), Body: stringPtr("LGTM")},
+				{ID: int64Ptr(42), Body: stringPtr(summaryMarker + "\nold"), User: bot},
+			},
+			wantEdit: true,
+		},
+		{
+			name: "summary marker copied by someone else",
+			comments: []*github.IssueComment{
+				{ID: int64Ptr(42), Body: stringPtr(summaryMarker + "\nold"), User: bot},
+				{ID: int64Ptr(43), Body: stringPtr("> " + summaryMarker + "\nquoted"), User: &github.User{Login: stringPtr("someone")}},
+			},
+			wantEdit: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeIssueClient{comments: tt.comments}
+
+			poster := NewCommentPoster(fake, "owner", "repo", 7)
+			err := poster.PostResults(context.Background(), []*Result{{Branch: "release-1.0", Success: true, NewPR: &github.PullRequest{Number: intPtr(10)}}})
+			if err != nil {
+				t.Fatalf("PostResults() error = %v", err)
+			}
+
+			posted := fake.created
+			if tt.wantEdit {
+				posted = nil
+				for _, edit := range fake.edited {
+					if edit.id != 42 {
+						t.Errorf("Expected comment 42 to be edited, got %d", edit.id)
+					}
+					posted = append(posted, edit.body)
+				}
+				if len(fake.created) != 0 {
+					t.Error("Expected no new comment")
+				}
+			} else if len(fake.edited) != 0 {
+				t.Error("Expected no comment to be edited")
+			}
+
+			if len(posted) != 1 || !strings.Contains(posted[0], summaryMarker) || !strings.Contains(posted[0], "release-1.0") {
+				t.Errorf("Expected summary to be posted once, got %q", posted)
+			}
+		})
+	}
+}
+
+func TestPostResults_RepostsUneditableSummary(t *testing.T) {
+	fake := &fakeIssueClient{
+		comments: []*github.IssueComment{
+			{ID: int64Ptr(42), Body: stringPtr(summaryMarker + "\nold"), User: &github.User{Login: stringPtr("bot")}},
+		},
+		editErr: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}},
+	}
+	poster := NewCommentPoster(fake, "owner", "repo", 7)
+	results := []*Result{{Branch: "release-1.0", Success: true, NewPR: &github.PullRequest{Number: intPtr(10)}}}
+
+	// The summary was deleted: a new one is posted
+	if err := poster.PostResults(context.Background(), results); err != nil {
+		t.Fatalf("PostResults() error = %v", err)
+	}
+	if len(fake.created) != 1 || !strings.Contains(fake.created[0], summaryMarker) {
+		t.Fatalf("Expected a new summary, got %q", fake.created)
+	}
+
+	// and is the one edited afterwards
+	fake.editErr = nil
+	if err := poster.PostResults(context.Background(), results); err != nil {
+		t.Fatalf("PostResults() error = %v", err)
+	}
+	if len(fake.edited) != 1 || fake.edited[0].id != 101 {
+		t.Errorf("Expected the new summary 101 to be edited, got %+v", fake.edited)
+	}
+
+	// Other failures are returned
+	fake.editErr = errors.New("boom")
+	if err := poster.PostResults(context.Background(), results); err == nil {
+		t.Error("Expected an error")
+	}
+	if len(fake.created) != 1 {
+		t.Errorf("Expected no other summary, got %q", fake.created)
+	}
+}
+
+func TestReportProgress_EditsSummary(t *testing.T) {
+	fake := &fakeIssueClient{}
+	poster := NewCommentPoster(fake, "owner", "repo", 7)
+	ctx := context.Background()
+
+	// Events before StartProgress are ignored
+	poster.ReportProgress(ctx, Event{Branch: "release-1.0", Stage: StageFetching})
+	if len(fake.created)+len(fake.edited) != 0 {
+		t.Fatal("Expected no comment before StartProgress")
+	}
+
+	if err := poster.StartProgress(ctx, []string{"release-1.0", "release-1.1"}); err != nil {
+		t.Fatalf("StartProgress() error = %v", err)
+	}
+	if len(fake.created) != 1 || !strings.Contains(fake.created[0], "in progress") ||
+		strings.Count(fake.created[0], "⏳ Pending") != 2 {
+		t.Fatalf("Expected an in-progress comment with pending branches, got %q", fake.created)
+	}
+
+	poster.ReportProgress(ctx, Event{Branch: "release-1.0", Stage: StagePicking})
+	poster.ReportProgress(ctx, Event{Branch: "release-1.1", Stage: StagePROpened, PR: &github.PullRequest{Number: intPtr(12)}})
+
+	if len(fake.edited) != 2 {
+		t.Fatalf("Expected 2 edits, got %d", len(fake.edited))
+	}
+	last := fake.edited[1]
+	if last.id != 101 {
+		t.Errorf("Expected the progress comment to be edited, got %d", last.id)
+	}
+	for _, row := range []string{"| `release-1.0` | 🍒 Cherry-picking |", "| `release-1.1` | 🔀 Opened #12 |"} {
+		if !strings.Contains(last.body, row) {
+			t.Errorf("Expected row %q in progress comment:\n%s", row, last.body)
+		}
+	}
+
+	// The final summary replaces the progress in the same comment
+	if err := poster.PostResults(ctx, []*Result{{Branch: "release-1.0", Success: true, NewPR: &github.PullRequest{Number: intPtr(11)}}}); err != nil {
+		t.Fatalf("PostResults() error = %v", err)
+	}
+	if len(fake.created) != 1 {
+		t.Errorf("Expected no new comment, got %d", len(fake.created))
+	}
+	if final := fake.edited[len(fake.edited)-1]; final.id != 101 || !strings.Contains(final.body, "Cherry-pick summary") {
+		t.Errorf("Expected the final summary in the progress comment, got %+v", final)
+	}
+}
+
+func TestReactionLifecycle(t *testing.T) {
+	fake := &fakeIssueClient{}
+	poster := NewCommentPoster(fake, "owner", "repo", 7)
+	ctx := context.Background()
+
+	ack, err := poster.AddReaction(ctx, 5, "eyes")
+	if err != nil || ack != 1 {
+		t.Fatalf("AddReaction() = %d, %v, want 1", ack, err)
+	}
+
+	if _, err := poster.AddReaction(ctx, 5, "rocket"); err != nil {
+		t.Fatalf("AddReaction() error = %v", err)
+	}
+
+	if err := poster.RemoveReaction(ctx, 5, ack); err != nil {
+		t.Fatalf("RemoveReaction() error = %v", err)
+	}
+
+	if !slices.Equal(fake.reactions, []string{"eyes", "rocket"}) {
+		t.Errorf("Expected eyes then rocket reactions, got %v", fake.reactions)
+	}
+
+	if !slices.Equal(fake.deleted, []int64{ack}) {
+		t.Errorf("Expected the eyes reaction to be removed, got %v", fake.deleted)
+	}
+
+	// Without a trigger comment or reaction there is nothing to do
+	if id, err := poster.AddReaction(ctx, 0, "eyes"); id != 0 || err != nil {
+		t.Errorf("AddReaction() without comment = %d, %v", id, err)
+	}
+	if err := poster.RemoveReaction(ctx, 5, 0); err != nil || len(fake.deleted) != 1 {
+		t.Errorf("RemoveReaction() without reaction = %v", err)
+	}
+}
+
+func TestPostError(t *testing.T) {
+	fake := &fakeIssueClient{}
+	poster := NewCommentPoster(fake, "owner", "repo", 7)
+
+	if err := poster.PostError(context.Background(), "at least one target branch is required"); err != nil {
+		t.Fatalf("PostError() error = %v", err)
+	}
+
+	if len(fake.created) != 1 {
+		t.Fatalf("Expected 1 comment, got %d", len(fake.created))
+	}
+
+	if !strings.Contains(fake.created[0], "at least one target branch is required") {
+		t.Error("Expected the error message in the comment")
+	}
+
+	if !strings.Contains(fake.created[0], "**Usage**") {
+		t.Error("Expected usage in the comment")
+	}
+}
+
+func TestCommentPoster_NoIssueNumber(t *testing.T) {
+	fake := &fakeIssueClient{}
+	poster := NewCommentPoster(fake, "owner", "repo", 0)
+	ctx := context.Background()
+	results := []*Result{{Branch: "release-1.0", Success: true}}
+
+	if err := poster.PostError(ctx, "boom"); err != nil {
+		t.Errorf("PostError() error = %v", err)
+	}
+	if err := poster.StartProgress(ctx, []string{"release-1.0"}); err != nil {
+		t.Errorf("StartProgress() error = %v", err)
+	}
+	poster.ReportProgress(ctx, Event{Branch: "release-1.0", Stage: StagePicking})
+	if err := poster.PostResults(ctx, results); err != nil {
+		t.Errorf("PostResults() error = %v", err)
+	}
+
+	if len(fake.created) != 0 || len(fake.edited) != 0 {
+		t.Errorf("Expected nothing to be posted without an issue number, got %d created and %d edited", len(fake.created), len(fake.edited))
+	}
+}
+
+func TestCommentPoster_ErrorPropagation(t *testing.T) {
+	clientErr := errors.New("API rate limit exceeded")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func(poster *CommentPoster) error
+	}{
+		{
+			name: "PostError",
+			call: func(poster *CommentPoster) error { return poster.PostError(ctx, "boom") },
+		},
+		{
+			name: "StartProgress",
+			call: func(poster *CommentPoster) error { return poster.StartProgress(ctx, []string{"release-1.0"}) },
+		},
+		{
+			name: "PostResults",
+			call: func(poster *CommentPoster) error { return poster.PostResults(ctx, []*Result{{Branch: "release-1.0"}}) },
+		},
+		{
+			name: "AddReaction",
+			call: func(poster *CommentPoster) error {
+				_, err := poster.AddReaction(ctx, 5, "eyes")
+				return err
+			},
+		},
+		{
+			name: "RemoveReaction",
+			call: func(poster *CommentPoster) error { return poster.RemoveReaction(ctx, 5, 1) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := NewCommentPoster(&fakeIssueClient{err: clientErr}, "owner", "repo", 7)
+
+			if err := tt.call(poster); !errors.Is(err, clientErr) {
+				t.Errorf("Expected the client error to be propagated, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFormatResult_NextStepsPerClass(t *testing.T) {
+	poster := &CommentPoster{}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: classify(ErrNotMerged, errors.New("not merged")), want: "Merge the PR first"},
+		{err: classify(ErrBranchNotFound, errors.New("no branch")), want: "Check the spelling"},
+		{err: &BranchNotFoundError{Branch: "relase-1.0"}, want: "Check the spelling"},
+		{err: &BranchNotFoundError{Branch: "relase-1.0", Suggestions: []string{"release-1.0"}}, want: "- `/cherry-pick release-1.0`"},
+		{err: classify(ErrConflict, errors.New("conflict")), want: "Resolve the conflicts locally"},
+		{err: classify(ErrPushRejected, errors.New("rejected")), want: "allowed to push"},
+		{err: classify(ErrPRCreationFailed, errors.New("422")), want: "open a pull request from it"},
+		{err: errors.New("something else"), want: "If the PR is not merged"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			body := poster.formatResult(&Result{Branch: "release-1.0", Error: tt.err, ErrorMessage: tt.err.Error()})
+
+			if !strings.Contains(body, "Next steps") {
+				t.Error("Expected 'Next steps' in comment body")
+			}
+
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("Expected %q in comment body:\n%s", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package cherrypick
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// validateCommitSpec checks that spec is a commit SHA or an A..B range
+func validateCommitSpec(spec string) error {
+	refs := []string{spec}
+	if base, head, ok := strings.Cut(spec, ".."); ok {
+		refs = []string{base, head}
+	}
+
+	for _, ref := range refs {
+		if ref == "" || strings.Trim(ref, "0123456789abcdefABCDEF") != "" {
+			return fmt.Errorf("invalid commit %q: expected a SHA or a SHA..SHA range", spec)
+		}
+	}
+	return nil
+}
+
+// planCommitPicks resolves the commits requested in cfg.Commits, in the order
+// given. Ranges are expanded like git's A..B: every commit reachable from B
+// but not from A, oldest first. Merge commits are picked against their first
+// parent.
+func (s *Service) planCommitPicks(ctx context.Context, cfg *Config) (*pickPlan, error) {
+	plan := &pickPlan{}
+
+	for _, spec := range cfg.Commits {
+		if base, head, ok := strings.Cut(spec, ".."); ok {
+			commits, err := s.github.CompareCommits(ctx, cfg.RepoOwner, cfg.RepoName, base, head)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list commits in %s: %w", spec, err)
+			}
+			if len(commits) == 0 {
+				return nil, fmt.Errorf("range %s contains no commits", spec)
+			}
+			for _, commit := range commits {
+				if len(commit.Parents) > 1 {
+					plan.mainline = true
+				}
+				plan.commits = append(plan.commits, commit.GetSHA())
+				plan.subjects = append(plan.subjects, subject(commit.GetCommit().GetMessage()))
+			}
+			continue
+		}
+
+		commit, err := s.github.GetCommit(ctx, cfg.RepoOwner, cfg.RepoName, spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch commit %s: %w", spec, err)
+		}
+		if len(commit.Parents) > 1 {
+			plan.mainline = true
+		}
+		sha := commit.GetSHA()
+		if sha == "" {
+			sha = spec
+		}
+		plan.commits = append(plan.commits, sha)
+		plan.subjects = append(plan.subjects, subject(commit.GetMessage()))
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,143 @@
+package cherrypick
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestPlanCommitPicks(t *testing.T) {
+	mockGH := &mockGitHubClient{
+		getCommit: func(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+			return &github.Commit{
+				SHA:     stringPtr(sha + "000000"),
+				Message: stringPtr("Single fix\n\nBody"),
+				Parents: []*github.Commit{{SHA: stringPtr("p")}},
+			}, nil
+		},
+		compareCommits: func(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+			if base != "aaa" || head != "ccc" {
+				t.Errorf("Unexpected range %s..%s", base, head)
+			}
+			return []*github.RepositoryCommit{
+				{SHA: stringPtr("bbb"), Commit: &github.Commit{Message: stringPtr("Range one")}},
+				{SHA: stringPtr("ccc"), Commit: &github.Commit{Message: stringPtr("Range two")}},
+			}, nil
+		},
+	}
+
+	service := NewService(mockGH, &mockGitRunner{})
+	cfg := &Config{Commits: []string{"fff", "aaa..ccc"}, RepoOwner: "owner", RepoName: "repo"}
+
+	plan, err := service.planCommitPicks(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("planCommitPicks() error = %v", err)
+	}
+
+	want := &pickPlan{
+		commits:  []string{"fff000000", "bbb", "ccc"},
+		subjects: []string{"Single fix", "Range one", "Range two"},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("planCommitPicks() = %+v, want %+v", plan, want)
+	}
+}
+
+func TestPlanCommitPicks_EmptyRange(t *testing.T) {
+	service := NewService(&mockGitHubClient{}, &mockGitRunner{})
+	cfg := &Config{Commits: []string{"aaa..bbb"}, RepoOwner: "owner", RepoName: "repo"}
+
+	if _, err := service.planCommitPicks(context.Background(), cfg); err == nil {
+		t.Error("Expected an error for an empty range")
+	}
+}
+
+func TestProcessBranch_CommitsRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodMerge)
+	fix, docs := repos.prCommits[0], repos.prCommits[1]
+
+	tests := []struct {
+		name       string
+		commits    []string
+		wantBranch string
+		wantFiles  []string
+		skipFiles  []string
+	}{
+		{
+			name:       "single commit",
+			commits:    []string{docs[:10]},
+			wantBranch: "cherry-pick-" + docs[:7] + "-to-release-v1.0",
+			wantFiles:  []string{"docs.txt"},
+			skipFiles:  []string{"fix.txt"},
+		},
+		{
+			name:       "range",
+			commits:    []string{fix + "^.." + docs},
+			wantBranch: "cherry-pick-" + fix[:7] + "-" + docs[:7] + "-to-release-v1.0",
+			wantFiles:  []string{"fix.txt", "docs.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *github.NewPullRequest
+			mockGH := newRealGitHubClient(t, repos)
+			mockGH.getPR = func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+				t.Error("Unexpected PR lookup in commits mode")
+				return nil, nil
+			}
+			mockGH.createPR = func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+				created = pr
+				return &github.PullRequest{Number: intPtr(2)}, nil
+			}
+
+			service := NewService(mockGH, &CommandGitRunner{Dir: repos.clone})
+			cfg := &Config{
+				Commits:      tt.commits,
+				RepoOwner:    "owner",
+				RepoName:     "repo",
+				GitUserName:  "Test Bot",
+				GitUserEmail: "bot@test.com",
+			}
+
+			result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+			if !result.Success {
+				t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+			}
+
+			if created.GetHead() != tt.wantBranch {
+				t.Errorf("Expected branch %s, got %s", tt.wantBranch, created.GetHead())
+			}
+
+			files := gitRepo(t, repos.origin, "ls-tree", "--name-only", tt.wantBranch)
+			for _, file := range tt.wantFiles {
+				if !strings.Contains(files, file) {
+					t.Errorf("Expected %s on the cherry-pick branch, got:\n%s", file, files)
+				}
+			}
+			for _, file := range tt.skipFiles {
+				if strings.Contains(files, file) {
+					t.Errorf("Unexpected %s on the cherry-pick branch", file)
+				}
+			}
+
+			// Each source commit is linked from the body
+			for _, sha := range strings.Fields(gitRepo(t, repos.origin, "rev-list", "release-v1.0.."+tt.wantBranch)) {
+				source := gitRepo(t, repos.origin, "log", "-1", "--format=%s", sha)
+				if !strings.Contains(created.GetBody(), source) {
+					t.Errorf("Expected %q in PR body:\n%s", source, created.GetBody())
+				}
+			}
+			for _, sha := range repos.prCommits {
+				link := "https://github.com/owner/repo/commit/" + sha
+				picked := strings.Contains(tt.wantBranch, sha[:7])
+				if picked && !strings.Contains(created.GetBody(), link) {
+					t.Errorf("Expected link %s in PR body:\n%s", link, created.GetBody())
+				}
+			}
+		})
+	}
+}
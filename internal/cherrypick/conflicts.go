@@ -0,0 +1,164 @@
+package cherrypick
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// maxConflictDiffLines bounds the diff kept for each conflicting file, so
+// that a large conflict doesn't blow up the PR comment
+const maxConflictDiffLines = 80
+
+// conflictLabel marks the draft PRs opened for conflicting cherry-picks
+const conflictLabel = "cherry-pick-conflict"
+
+// collectConflicts lists the unmerged paths of a stopped cherry-pick in dir,
+// along with their conflict hunks
+func (s *Service) collectConflicts(ctx context.Context, dir string) []ConflictFile {
+	paths, err := s.runGit(ctx, dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		log.Printf("Warning: failed to list conflicting files: %v", err)
+		return nil
+	}
+
+	var files []ConflictFile
+	for _, path := range strings.Split(paths, "\n") {
+		if path == "" {
+			continue
+		}
+
+		file := ConflictFile{Path: path}
+		diff, err := s.runGit(ctx, dir, "diff", "--diff-filter=U", "--", path)
+		if err != nil {
+			log.Printf("Warning: failed to diff conflicting file %s: %v", path, err)
+		} else {
+			file.Diff, file.Hunks = conflictHunks(diff)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// conflictHunks strips the header of a combined diff, keeping the hunks
+// (truncated to maxConflictDiffLines), and counts the conflict regions
+func conflictHunks(diff string) (string, int) {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "@@") {
+			lines = lines[i:]
+			break
+		}
+	}
+
+	hunks := 0
+	for _, line := range lines {
+		// Combined diffs prefix every line with one column per parent
+		if strings.HasPrefix(strings.TrimLeft(line, " +-"), "<<<<<<<") {
+			hunks++
+		}
+	}
+
+	if len(lines) > maxConflictDiffLines {
+		lines = append(lines[:maxConflictDiffLines], "... (truncated)")
+	}
+	return strings.Join(lines, "\n"), hunks
+}
+
+// conflictCount describes the number of conflict hunks of a file
+func conflictCount(hunks int) string {
+	if hunks == 1 {
+		return "1 conflict"
+	}
+	return fmt.Sprintf("%d conflicts", hunks)
+}
+
+// resolveConflicts tries to finish the pick stopped in dir with err. A pick
+// whose conflicts were all resolved from the rerere cache is continued and,
+// with cfg.DraftOnConflict, conflicts are committed with their markers. It
+// returns the committed conflicts, or an error (a ConflictError on conflicts)
+// when the pick cannot be finished.
+func (s *Service) resolveConflicts(ctx context.Context, cfg *Config, dir string, err error) ([]ConflictFile, error) {
+	conflicts := s.collectConflicts(ctx, dir)
+	if len(conflicts) == 0 && (cfg.RerereCache == "" || !s.pickInProgress(ctx, dir)) {
+		return nil, err
+	}
+
+	if len(conflicts) > 0 {
+		if !cfg.DraftOnConflict {
+			return nil, &ConflictError{Files: conflicts, Err: err}
+		}
+		if _, err := s.runGit(ctx, dir, "add", "--all"); err != nil {
+			return nil, fmt.Errorf("failed to stage conflicts: %w", err)
+		}
+	}
+
+	if _, err := s.git.Run(ctx, s.pickCommand(cfg, dir, "cherry-pick", "--continue")); err != nil {
+		return nil, fmt.Errorf("failed to continue cherry-pick: %w", err)
+	}
+	return conflicts, nil
+}
+
+// pickInProgress reports whether a cherry-pick is stopped in dir
+func (s *Service) pickInProgress(ctx context.Context, dir string) bool {
+	_, err := s.runGit(ctx, dir, "rev-parse", "--quiet", "--verify", "CHERRY_PICK_HEAD")
+	return err == nil
+}
+
+// mergeConflicts adds more to conflicts, summing the hunks of paths that
+// conflicted in several picks
+func mergeConflicts(conflicts, more []ConflictFile) []ConflictFile {
+	for _, file := range more {
+		i := slices.IndexFunc(conflicts, func(c ConflictFile) bool { return c.Path == file.Path })
+		if i < 0 {
+			conflicts = append(conflicts, file)
+			continue
+		}
+		conflicts[i].Hunks += file.Hunks
+		conflicts[i].Diff = file.Diff
+	}
+	return conflicts
+}
+
+// openDraftPR opens a labelled draft PR for a cherry-pick of source pushed
+// with its conflict markers, listing the files to fix in its body. The result
+// stays a conflict failure, pointing at the draft.
+func (s *Service) openDraftPR(ctx context.Context, cfg *Config, result *Result, source *github.PullRequest, cherryPickBranch, title, body string, conflicts []ConflictFile) {
+	result.Conflicts = conflicts
+
+	var b strings.Builder
+	b.WriteString(body)
+	b.WriteString("\n\n### ⚠️ Conflicts\n\n")
+	b.WriteString("The cherry-pick conflicts with the target branch and was committed with its conflict markers. " +
+		"Fix the following files, then mark this PR as ready for review:\n\n")
+	for _, file := range conflicts {
+		fmt.Fprintf(&b, "- [ ] `%s` (%s)\n", file.Path, conflictCount(file.Hunks))
+	}
+	body = b.String()
+
+	draft := true
+	pr, err := s.github.CreatePR(ctx, cfg.RepoOwner, cfg.RepoName, &github.NewPullRequest{
+		Title: &title,
+		Body:  &body,
+		Head:  &cherryPickBranch,
+		Base:  &result.Branch,
+		Draft: &draft,
+	})
+	if err != nil {
+		result.Error = classify(ErrPRCreationFailed, err)
+		result.ErrorMessage = fmt.Sprintf("Failed to create draft pull request: %v", err)
+		return
+	}
+
+	s.copyMetadata(ctx, cfg, result, source, pr, conflictLabel)
+
+	log.Printf("⚠️  Cherry-pick has conflicts, draft PR #%d created", pr.GetNumber())
+	s.report(ctx, Event{Branch: result.Branch, Stage: StagePROpened, PR: pr})
+	result.DraftPR = pr
+	result.Error = &ConflictError{Files: conflicts, Err: fmt.Errorf("cherry-pick has conflicts, committed to draft PR #%d", pr.GetNumber())}
+	result.ErrorMessage = result.Error.Error()
+}
@@ -0,0 +1,168 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestConflictHunks(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --cc fix.txt",
+		"index 1a2b3c4,5d6e7f8..0000000",
+		"--- a/fix.txt",
+		"+++ b/fix.txt",
+		"@@@ -1,1 -1,1 +1,5 @@@",
+		"++<<<<<<< HEAD",
+		" +release",
+		"++=======",
+		"+ fix",
+		"++>>>>>>> abc1234 (Fix things)",
+		"@@@ -10,1 -10,1 +14,5 @@@",
+		"++<<<<<<< HEAD",
+		"++=======",
+		"++>>>>>>> abc1234 (Fix things)",
+	}, "\n")
+
+	hunks, count := conflictHunks(diff)
+	if count != 2 {
+		t.Errorf("Expected 2 conflict hunks, got %d", count)
+	}
+	if !strings.HasPrefix(hunks, "@@@ -1,1") {
+		t.Errorf("Expected the diff header to be stripped, got:\n%s", hunks)
+	}
+
+	long := "@@ -1 +1 @@\n" + strings.Repeat("+line\n", 2*maxConflictDiffLines)
+	hunks, _ = conflictHunks(long)
+	lines := strings.Split(hunks, "\n")
+	if len(lines) != maxConflictDiffLines+1 || lines[len(lines)-1] != "... (truncated)" {
+		t.Errorf("Expected the diff to be truncated to %d lines, got %d", maxConflictDiffLines, len(lines))
+	}
+}
+
+func TestProcessBranch_ConflictsRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodMerge)
+	addConflicts(t, repos, "release-v1.0", "fix.txt")
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:     1,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if result.Success {
+		t.Fatal("Expected the cherry-pick to fail")
+	}
+	if !errors.Is(result.Error, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", result.Error)
+	}
+
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected 1 conflicting file, got %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Path != "fix.txt" {
+		t.Errorf("Expected fix.txt to conflict, got %s", conflict.Path)
+	}
+	if conflict.Hunks != 1 {
+		t.Errorf("Expected 1 conflict hunk, got %d", conflict.Hunks)
+	}
+	if !strings.Contains(conflict.Diff, "release") || !strings.Contains(conflict.Diff, "fix") {
+		t.Errorf("Expected both sides in the diff, got:\n%s", conflict.Diff)
+	}
+}
+
+// addConflicts commits files on branch with content disagreeing with the PR
+func addConflicts(t *testing.T, repos *realRepos, branch string, files ...string) {
+	t.Helper()
+	gitRepo(t, repos.clone, "checkout", branch)
+	gitRepo(t, repos.clone, "config", "user.name", "Author")
+	gitRepo(t, repos.clone, "config", "user.email", "author@test.com")
+	for _, file := range files {
+		writeFile(t, filepath.Join(repos.clone, file), "release\n")
+	}
+	gitRepo(t, repos.clone, "add", ".")
+	gitRepo(t, repos.clone, "commit", "-m", "Release changes")
+	gitRepo(t, repos.clone, "push", "origin", branch)
+	gitRepo(t, repos.clone, "checkout", "main")
+}
+
+func TestProcessBranch_DraftOnConflictRealGit(t *testing.T) {
+	// Both commits of the rebased PR conflict, so the pick stops twice
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodRebase)
+	addConflicts(t, repos, "release-v1.0", "fix.txt", "docs.txt")
+
+	var created *github.NewPullRequest
+	var labels []string
+	mockGH := newRealGitHubClient(t, repos)
+	mockGH.createPR = func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+		created = pr
+		return &github.PullRequest{Number: intPtr(2), HTMLURL: stringPtr("https://github.com/owner/repo/pull/2")}, nil
+	}
+	mockGH.addLabels = func(ctx context.Context, owner, repo string, number int, l []string) error {
+		if number != 2 {
+			t.Errorf("Expected labels on #2, got #%d", number)
+		}
+		labels = l
+		return nil
+	}
+
+	service := NewService(mockGH, &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:        1,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		DraftOnConflict: true,
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if result.Success {
+		t.Fatal("Expected a conflicting cherry-pick not to succeed")
+	}
+	if !errors.Is(result.Error, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", result.Error)
+	}
+	if result.DraftPR.GetNumber() != 2 {
+		t.Fatalf("Expected draft PR #2, got %+v (%s)", result.DraftPR, result.ErrorMessage)
+	}
+
+	if !created.GetDraft() {
+		t.Error("Expected a draft PR")
+	}
+	if !slices.Equal(labels, []string{conflictLabel}) {
+		t.Errorf("Expected the %s label, got %v", conflictLabel, labels)
+	}
+	for _, want := range []string{"- [ ] `fix.txt` (1 conflict)", "- [ ] `docs.txt` (1 conflict)"} {
+		if !strings.Contains(created.GetBody(), want) {
+			t.Errorf("Expected %q in the PR body, got:\n%s", want, created.GetBody())
+		}
+	}
+
+	// Both picks are committed on the pushed branch, markers included
+	branch := "cherry-pick-1-to-release-v1.0"
+	if count := gitRepo(t, repos.origin, "rev-list", "--count", "release-v1.0.."+branch); count != "2" {
+		t.Errorf("Expected 2 commits on %s, got %s", branch, count)
+	}
+	for _, file := range []string{"fix.txt", "docs.txt"} {
+		if content := gitRepo(t, repos.origin, "show", branch+":"+file); !strings.Contains(content, "<<<<<<<") {
+			t.Errorf("Expected conflict markers in %s, got:\n%s", file, content)
+		}
+	}
+	if subject := gitRepo(t, repos.origin, "log", "-1", "--format=%s", branch); subject != "Document fix" {
+		t.Errorf("Expected the picked commit message, got %q", subject)
+	}
+	if message := gitRepo(t, repos.origin, "log", "-1", "--format=%B", branch); !strings.Contains(message, "(cherry picked from commit ") {
+		t.Errorf("Expected the origin of the conflicting pick to be recorded, got:\n%s", message)
+	}
+}
@@ -0,0 +1,112 @@
+package cherrypick
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// DeferredLabelPrefix starts the labels recording the cherry-picks requested
+// on an open PR, cherry-pick/<branch>, which run once it is merged
+const DeferredLabelPrefix = "cherry-pick/"
+
+// RemoveLabel removes a label from an issue or pull request. A label that is
+// not there is not an error.
+func (c *DefaultGitHubClient) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	resp, err := c.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Defer records the cherry-picks of the PR of cfg to cfg.Branches while the
+// PR is open, returning all the branches recorded on it. It returns nil when
+// the PR is merged or closed, or when picking commits, which do not wait for
+// the merge.
+//
+// Only the branches are recorded: the cherry-picks use the options of the run
+// started by the merge, see DeferredBranches.
+func (s *Service) Defer(ctx context.Context, cfg *Config) ([]string, error) {
+	if cfg.PRNumber == 0 || len(cfg.Commits) > 0 {
+		return nil, nil
+	}
+
+	pr, err := s.github.GetPR(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR #%d: %w", cfg.PRNumber, err)
+	}
+	if pr.GetMerged() || pr.GetState() != "open" {
+		return nil, nil
+	}
+
+	var labels []string
+	for _, branch := range cfg.Branches {
+		labels = append(labels, DeferredLabelPrefix+branch)
+	}
+	log.Printf("PR #%d is not merged yet, recording the cherry-picks to %s", cfg.PRNumber, strings.Join(cfg.Branches, ", "))
+	if err := s.github.AddLabels(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber, labels); err != nil {
+		return nil, fmt.Errorf("failed to record the cherry-picks of #%d: %w", cfg.PRNumber, err)
+	}
+
+	branches := deferredBranches(pr.Labels)
+	for _, branch := range cfg.Branches {
+		if !slices.Contains(branches, branch) {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
+// DeferredBranches returns the branches of the cherry-picks recorded on the
+// PR of cfg while it was open, once it is merged. It returns nil while the PR
+// is not merged.
+func (s *Service) DeferredBranches(ctx context.Context, cfg *Config) ([]string, error) {
+	pr, err := s.github.GetPR(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR #%d: %w", cfg.PRNumber, err)
+	}
+	if !pr.GetMerged() {
+		return nil, nil
+	}
+	return deferredBranches(pr.Labels), nil
+}
+
+// ClearDeferred removes the records of the cherry-picks of results that did
+// not fail, keeping the failed ones in view. Failures are only logged.
+func (s *Service) ClearDeferred(ctx context.Context, cfg *Config, results []*Result) {
+	if cfg.PRNumber == 0 || len(cfg.Commits) > 0 {
+		return
+	}
+
+	pr, err := s.github.GetPR(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+	if err != nil {
+		log.Printf("Warning: failed to fetch PR #%d: %v", cfg.PRNumber, err)
+		return
+	}
+	deferred := deferredBranches(pr.Labels)
+	for _, result := range results {
+		if result.Failed() || !slices.Contains(deferred, result.Branch) {
+			continue
+		}
+		if err := s.github.RemoveLabel(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber, DeferredLabelPrefix+result.Branch); err != nil {
+			log.Printf("Warning: failed to remove the %s%s label: %v", DeferredLabelPrefix, result.Branch, err)
+		}
+	}
+}
+
+// deferredBranches returns the branches of the deferred cherry-pick labels
+func deferredBranches(labels []*github.Label) []string {
+	var branches []string
+	for _, label := range labels {
+		if branch, ok := strings.CutPrefix(label.GetName(), DeferredLabelPrefix); ok && branch != "" {
+			branches = append(branches, branch)
+		}
+	}
+	return branches
+}
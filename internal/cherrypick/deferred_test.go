@@ -0,0 +1,154 @@
+package cherrypick
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func labelsOf(names ...string) []*github.Label {
+	var labels []*github.Label
+	for _, name := range names {
+		labels = append(labels, &github.Label{Name: stringPtr(name)})
+	}
+	return labels
+}
+
+func TestDeferredBranches(t *testing.T) {
+	labels := labelsOf("kind/bug", "cherry-pick/release-v1.0", "cherry-pick-conflict", "cherry-pick/", "cherry-pick/release-v2.0")
+	if got, want := deferredBranches(labels), []string{"release-v1.0", "release-v2.0"}; !slices.Equal(got, want) {
+		t.Errorf("deferredBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	tests := []struct {
+		name    string
+		pr      *github.PullRequest
+		commits []string
+		want    []string
+		labels  []string
+	}{
+		{
+			name:   "open PR",
+			pr:     &github.PullRequest{State: stringPtr("open"), Labels: labelsOf("kind/bug")},
+			want:   []string{"release-v1.0", "release-v2.0"},
+			labels: []string{"cherry-pick/release-v1.0", "cherry-pick/release-v2.0"},
+		},
+		{
+			name:   "open PR with recorded cherry-picks",
+			pr:     &github.PullRequest{State: stringPtr("open"), Labels: labelsOf("cherry-pick/release-v0.9", "cherry-pick/release-v1.0")},
+			want:   []string{"release-v0.9", "release-v1.0", "release-v2.0"},
+			labels: []string{"cherry-pick/release-v1.0", "cherry-pick/release-v2.0"},
+		},
+		{
+			name: "merged PR",
+			pr:   &github.PullRequest{State: stringPtr("closed"), Merged: boolPtr(true)},
+		},
+		{
+			name: "closed PR",
+			pr:   &github.PullRequest{State: stringPtr("closed")},
+		},
+		{
+			name:    "commits",
+			commits: []string{"abc1234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels []string
+			mockGH := &mockGitHubClient{
+				getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+					if tt.pr == nil {
+						t.Error("Unexpected PR lookup")
+					}
+					return tt.pr, nil
+				},
+				addLabels: func(ctx context.Context, owner, repo string, number int, l []string) error {
+					if number != 123 {
+						t.Errorf("Expected labels on #123, got #%d", number)
+					}
+					labels = l
+					return nil
+				},
+			}
+			service := NewService(mockGH, &mockGitRunner{})
+
+			cfg := &Config{
+				PRNumber:  123,
+				Commits:   tt.commits,
+				Branches:  []string{"release-v1.0", "release-v2.0"},
+				RepoOwner: "owner",
+				RepoName:  "repo",
+			}
+
+			got, err := service.Defer(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("Defer() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Defer() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(labels, tt.labels) {
+				t.Errorf("Expected labels %v, got %v", tt.labels, labels)
+			}
+		})
+	}
+}
+
+func TestService_DeferredBranches(t *testing.T) {
+	for _, merged := range []bool{true, false} {
+		mockGH := &mockGitHubClient{
+			getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+				return &github.PullRequest{Merged: boolPtr(merged), Labels: labelsOf("lgtm", "cherry-pick/release-v1.0")}, nil
+			},
+		}
+		service := NewService(mockGH, &mockGitRunner{})
+
+		branches, err := service.DeferredBranches(context.Background(), &Config{PRNumber: 123, RepoOwner: "owner", RepoName: "repo"})
+		if err != nil {
+			t.Fatalf("DeferredBranches() error = %v", err)
+		}
+		// The cherry-picks only run once the PR is merged
+		var want []string
+		if merged {
+			want = []string{"release-v1.0"}
+		}
+		if !slices.Equal(branches, want) {
+			t.Errorf("DeferredBranches() of a PR merged: %v = %v, want %v", merged, branches, want)
+		}
+	}
+}
+
+func TestClearDeferred(t *testing.T) {
+	var removed []string
+	mockGH := &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{
+				Merged: boolPtr(true),
+				Labels: labelsOf("cherry-pick/release-v1.0", "cherry-pick/release-v1.1", "cherry-pick/release-v1.2"),
+			}, nil
+		},
+		removeLabel: func(ctx context.Context, owner, repo string, number int, label string) error {
+			removed = append(removed, label)
+			return nil
+		},
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	results := []*Result{
+		{Branch: "release-v1.0", Success: true, NewPR: &github.PullRequest{Number: intPtr(10)}},
+		{Branch: "release-v1.1", Error: ErrConflict},
+		{Branch: "release-v1.2", Success: true, AlreadyApplied: true},
+		{Branch: "release-v2.0", Success: true, NewPR: &github.PullRequest{Number: intPtr(11)}},
+	}
+	service.ClearDeferred(context.Background(), &Config{PRNumber: 123, RepoOwner: "owner", RepoName: "repo"}, results)
+
+	// The failed cherry-pick stays recorded
+	if want := []string{"cherry-pick/release-v1.0", "cherry-pick/release-v1.2"}; !slices.Equal(removed, want) {
+		t.Errorf("Expected %v to be removed, got %v", want, removed)
+	}
+}
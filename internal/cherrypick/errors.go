@@ -0,0 +1,98 @@
+package cherrypick
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Classes of cherry-pick failures. Result.Error matches at most one of them
+// with errors.Is.
+var (
+	// ErrNotMerged means the PR to cherry-pick is not merged yet
+	ErrNotMerged = errors.New("pull request is not merged")
+	// ErrBranchNotFound means the target branch does not exist
+	ErrBranchNotFound = errors.New("target branch not found")
+	// ErrBranchNotAllowed means the repository configuration does not
+	// allow cherry-picks to the target branch
+	ErrBranchNotAllowed = errors.New("target branch not allowed")
+	// ErrConflict means the cherry-pick stopped on conflicting changes
+	ErrConflict = errors.New("cherry-pick conflict")
+	// ErrPushRejected means the cherry-pick branch could not be pushed
+	ErrPushRejected = errors.New("push rejected")
+	// ErrPRCreationFailed means the branch was pushed but opening the PR
+	// failed
+	ErrPRCreationFailed = errors.New("pull request creation failed")
+)
+
+// classifiedError attaches a class to an error without changing its message
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// classify marks err as belonging to class
+func classify(class, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// ConflictFile is a path left unmerged by a failed cherry-pick
+type ConflictFile struct {
+	Path string
+	// Diff is the combined diff of the path, with its conflict hunks. It is
+	// truncated for large files.
+	Diff string
+	// Hunks is the number of conflict hunks in the whole file
+	Hunks int
+}
+
+// ConflictError is returned when a cherry-pick stops on conflicts. It
+// matches ErrConflict with errors.Is.
+type ConflictError struct {
+	Files []ConflictFile
+	Err   error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v (%d conflicting files)", e.Err, len(e.Files))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// BranchNotFoundError is returned when a target branch does not exist, or no
+// branch matches a target glob. It matches ErrBranchNotFound with errors.Is.
+type BranchNotFoundError struct {
+	// Branch is the missing branch or the glob
+	Branch string
+	// Suggestions are existing branches with a close name, closest first
+	Suggestions []string
+}
+
+func (e *BranchNotFoundError) Error() string {
+	msg := fmt.Sprintf("target branch '%s' does not exist", e.Branch)
+	if isGlob(e.Branch) {
+		msg = fmt.Sprintf("no target branch matches '%s'", e.Branch)
+	}
+	if len(e.Suggestions) == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s (did you mean '%s'?)", msg, strings.Join(e.Suggestions, "', '"))
+}
+
+func (e *BranchNotFoundError) Is(target error) bool {
+	return target == ErrBranchNotFound
+}
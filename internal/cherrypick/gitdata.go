@@ -0,0 +1,318 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// GitDataClient defines the Git Data API operations creating cherry-pick
+// commits without a local clone
+type GitDataClient interface {
+	// GetRef returns the SHA a ref points to, or "" if it does not exist
+	GetRef(ctx context.Context, owner, repo, ref string) (string, error)
+	// GetTree returns a tree with all its descendants
+	GetTree(ctx context.Context, owner, repo, sha string) (*github.Tree, error)
+	CreateTree(ctx context.Context, owner, repo, baseTree string, entries []*github.TreeEntry) (*github.Tree, error)
+	CreateCommit(ctx context.Context, owner, repo string, commit *github.Commit) (*github.Commit, error)
+	// SetRef points a ref at sha, creating it if needed. An existing ref is
+	// only moved backwards or sideways when force is set.
+	SetRef(ctx context.Context, owner, repo, ref, sha string, force bool) error
+}
+
+func (c *DefaultGitHubClient) GetRef(ctx context.Context, owner, repo, ref string) (string, error) {
+	r, resp, err := c.client.Git.GetRef(ctx, owner, repo, ref)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return r.GetObject().GetSHA(), nil
+}
+
+func (c *DefaultGitHubClient) GetTree(ctx context.Context, owner, repo, sha string) (*github.Tree, error) {
+	tree, _, err := c.client.Git.GetTree(ctx, owner, repo, sha, true)
+	return tree, err
+}
+
+func (c *DefaultGitHubClient) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []*github.TreeEntry) (*github.Tree, error) {
+	tree, _, err := c.client.Git.CreateTree(ctx, owner, repo, baseTree, entries)
+	return tree, err
+}
+
+func (c *DefaultGitHubClient) CreateCommit(ctx context.Context, owner, repo string, commit *github.Commit) (*github.Commit, error) {
+	created, _, err := c.client.Git.CreateCommit(ctx, owner, repo, commit, nil)
+	return created, err
+}
+
+func (c *DefaultGitHubClient) SetRef(ctx context.Context, owner, repo, ref, sha string, force bool) error {
+	current, err := c.GetRef(ctx, owner, repo, ref)
+	if err != nil {
+		return err
+	}
+
+	reference := &github.Reference{
+		Ref:    github.String("refs/" + ref),
+		Object: &github.GitObject{SHA: &sha},
+	}
+	if current == "" {
+		_, _, err = c.client.Git.CreateRef(ctx, owner, repo, reference)
+	} else {
+		_, _, err = c.client.Git.UpdateRef(ctx, owner, repo, reference, force)
+	}
+	return err
+}
+
+// WithGitDataClient makes the service create the cherry-pick commits through
+// the Git Data API, falling back to the local clone for picks it cannot
+// apply
+func WithGitDataClient(client GitDataClient) Option {
+	return func(s *Service) {
+		s.gitData = client
+	}
+}
+
+var (
+	// errNotTrivial is returned by pickWithAPI for picks it leaves to git
+	errNotTrivial = errors.New("cannot be applied through the API")
+	// errEmptyPick is returned by applyChanges when all the changes are
+	// already applied
+	errEmptyPick = errors.New("the pick is empty")
+)
+
+// createBranch cherry-picks the commits of plan onto targetBranch as
+// cherryPickBranch, through the API when possible and else in a local
+// worktree. See performGitOperations for the returned conflicts.
+func (s *Service) createBranch(ctx context.Context, cfg *Config, targetBranch, cherryPickBranch string, plan *pickPlan, force bool) ([]ConflictFile, error) {
+	// Commits created through the API cannot be signed with our own key
+	if s.gitData != nil && s.signer == nil {
+		err := s.pickWithAPI(ctx, cfg, targetBranch, cherryPickBranch, plan, force)
+		if !errors.Is(err, errNotTrivial) {
+			return nil, err
+		}
+		log.Printf("Cherry-pick %v, falling back to git", err)
+	}
+	return s.performGitOperations(ctx, cfg, targetBranch, cherryPickBranch, plan, force)
+}
+
+// pickWithAPI creates the picks of plan on top of targetBranch through the
+// Git Data API and points cherryPickBranch at the last one, overwriting it
+// when force is set. Commits are created without author nor committer, which
+// is what makes GitHub sign them, and credit their original author with a
+// Co-authored-by trailer.
+//
+// Only picks whose changed paths are the same on targetBranch as in the
+// parent of the commit are applied, which is the result a 3-way merge would
+// give. Picks whose changes are all already applied are skipped, and
+// errAlreadyApplied is returned when all of them are. It returns
+// errNotTrivial for anything else, including conflicts and trees too large
+// for the API.
+func (s *Service) pickWithAPI(ctx context.Context, cfg *Config, targetBranch, cherryPickBranch string, plan *pickPlan, force bool) error {
+	owner, repo := cfg.RepoOwner, cfg.RepoName
+	s.report(ctx, Event{Branch: targetBranch, Stage: StageFetching})
+	head, err := s.gitData.GetRef(ctx, owner, repo, "heads/"+targetBranch)
+	if err != nil {
+		return fmt.Errorf("failed to look up target branch '%s': %w", targetBranch, err)
+	}
+	if head == "" {
+		return &BranchNotFoundError{Branch: targetBranch}
+	}
+
+	headCommit, err := s.github.GetCommit(ctx, owner, repo, head)
+	if err != nil {
+		return fmt.Errorf("failed to fetch head of %s: %w", targetBranch, err)
+	}
+	trees := map[string]map[string]*github.TreeEntry{}
+	tree := headCommit.GetTree().GetSHA()
+	target, err := s.treeFiles(ctx, cfg, trees, tree)
+	if err != nil {
+		return err
+	}
+	// The target files are updated as picks are applied
+	target = maps.Clone(target)
+
+	log.Printf("Cherry-picking %s through the API...", strings.Join(plan.commits, ", "))
+	s.report(ctx, Event{Branch: targetBranch, Stage: StagePicking})
+	parent := head
+	applied := 0
+	for _, sha := range plan.commits {
+		commit, err := s.github.GetCommit(ctx, owner, repo, sha)
+		if err != nil {
+			return fmt.Errorf("failed to fetch commit %s: %w", sha, err)
+		}
+		if len(commit.Parents) == 0 || (len(commit.Parents) > 1 && !plan.mainline) {
+			return fmt.Errorf("%w: %s is a root or merge commit", errNotTrivial, shortSHA(sha))
+		}
+		base, err := s.github.GetCommit(ctx, owner, repo, commit.Parents[0].GetSHA())
+		if err != nil {
+			return fmt.Errorf("failed to fetch parent of %s: %w", sha, err)
+		}
+
+		before, err := s.treeFiles(ctx, cfg, trees, base.GetTree().GetSHA())
+		if err != nil {
+			return err
+		}
+		after, err := s.treeFiles(ctx, cfg, trees, commit.GetTree().GetSHA())
+		if err != nil {
+			return err
+		}
+
+		entries, err := applyChanges(target, before, after)
+		if errors.Is(err, errEmptyPick) {
+			log.Printf("%s is already applied on %s, skipping it", shortSHA(sha), targetBranch)
+			applied++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errNotTrivial, shortSHA(sha), err)
+		}
+
+		newTree, err := s.gitData.CreateTree(ctx, owner, repo, tree, entries)
+		if err != nil {
+			return fmt.Errorf("failed to create tree for %s: %w", shortSHA(sha), err)
+		}
+		message := pickMessage(cfg, commit.GetMessage(), commit.GetSHA(), commit.GetAuthor())
+		newCommit, err := s.gitData.CreateCommit(ctx, owner, repo, &github.Commit{
+			Message: &message,
+			Tree:    &github.Tree{SHA: newTree.SHA},
+			Parents: []*github.Commit{{SHA: &parent}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create commit for %s: %w", shortSHA(sha), err)
+		}
+		tree, parent = newTree.GetSHA(), newCommit.GetSHA()
+	}
+	if applied == len(plan.commits) {
+		return errAlreadyApplied
+	}
+
+	log.Printf("Updating cherry-pick branch %s...", cherryPickBranch)
+	s.report(ctx, Event{Branch: targetBranch, Stage: StagePushing})
+	if err := s.gitData.SetRef(ctx, owner, repo, "heads/"+cherryPickBranch, parent, force); err != nil {
+		return classify(ErrPushRejected, fmt.Errorf("failed to update cherry-pick branch: %w", err))
+	}
+	return nil
+}
+
+// treeFiles returns the blobs and submodules of a tree by path, caching the
+// trees already fetched
+func (s *Service) treeFiles(ctx context.Context, cfg *Config, cache map[string]map[string]*github.TreeEntry, sha string) (map[string]*github.TreeEntry, error) {
+	if files, ok := cache[sha]; ok {
+		return files, nil
+	}
+
+	tree, err := s.gitData.GetTree(ctx, cfg.RepoOwner, cfg.RepoName, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tree %s: %w", sha, err)
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("%w: tree %s is too large", errNotTrivial, shortSHA(sha))
+	}
+
+	files := map[string]*github.TreeEntry{}
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "tree" {
+			files[entry.GetPath()] = entry
+		}
+	}
+	cache[sha] = files
+	return files, nil
+}
+
+// applyChanges applies the changes from before to after onto target,
+// returning the corresponding tree entries. A changed path must be the same
+// in target as in before, or already as in after. It returns errEmptyPick when
+// all of them are already as in after.
+func applyChanges(target, before, after map[string]*github.TreeEntry) ([]*github.TreeEntry, error) {
+	var paths []string
+	for path, entry := range before {
+		if !sameEntry(entry, after[path]) {
+			paths = append(paths, path)
+		}
+	}
+	for path := range after {
+		if before[path] == nil {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var entries []*github.TreeEntry
+	for _, path := range paths {
+		was, now := before[path], after[path]
+		switch current := target[path]; {
+		case sameEntry(current, now):
+			continue
+		case !sameEntry(current, was):
+			return nil, fmt.Errorf("%s conflicts", path)
+		case now == nil:
+			// A nil SHA deletes the path
+			entries = append(entries, &github.TreeEntry{Path: github.String(path), Mode: current.Mode, Type: current.Type})
+			delete(target, path)
+		default:
+			entries = append(entries, &github.TreeEntry{Path: github.String(path), Mode: now.Mode, Type: now.Type, SHA: now.SHA})
+			target[path] = now
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, errEmptyPick
+	}
+	return entries, nil
+}
+
+// sameEntry reports whether two tree entries, possibly nil for a missing
+// path, have the same content and mode
+func sameEntry(a, b *github.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.GetSHA() == b.GetSHA() && a.GetMode() == b.GetMode()
+}
+
+// pickMessage returns the message of the pick of sha, with the lines that
+// `git cherry-pick -x` and the configured trailers add, and a Co-authored-by
+// trailer for author, the original author the pick is not attributed to
+func pickMessage(cfg *Config, message, sha string, author *github.CommitAuthor) string {
+	lines := []string{"(cherry picked from commit " + sha + ")"}
+	coAuthor := fmt.Sprintf("Co-authored-by: %s <%s>", author.GetName(), author.GetEmail())
+	if author.GetEmail() != "" && !strings.Contains(message, coAuthor) {
+		lines = append(lines, coAuthor)
+	}
+	if cfg.Signoff {
+		lines = append(lines, fmt.Sprintf("Signed-off-by: %s <%s>", cfg.GitUserName, cfg.GitUserEmail))
+	}
+	lines = append(lines, cfg.Trailers...)
+
+	// Like git, extend a trailing block of trailers rather than starting a
+	// new paragraph
+	message = strings.TrimRight(message, "\n")
+	separator := "\n\n"
+	paragraphs := strings.Split(message, "\n\n")
+	if last := paragraphs[len(paragraphs)-1]; len(paragraphs) > 1 && isTrailerBlock(last) {
+		separator = "\n"
+	}
+	return message + separator + strings.Join(lines, "\n") + "\n"
+}
+
+// isTrailerBlock reports whether every line of paragraph is a trailer
+func isTrailerBlock(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if strings.HasPrefix(line, "(cherry picked from commit ") {
+			continue
+		}
+		token, _, ok := strings.Cut(line, ": ")
+		if !ok || token == "" || strings.ContainsAny(token, " \t") {
+			return false
+		}
+	}
+	return true
+}
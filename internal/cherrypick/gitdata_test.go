@@ -0,0 +1,288 @@
+package cherrypick
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// fakeGitData implements GitDataClient with git plumbing commands on a bare
+// repository
+type fakeGitData struct {
+	t    *testing.T
+	repo string
+	// commits are the commits created
+	commits []*github.Commit
+}
+
+// git runs git in the repository with extra environment variables
+func (f *fakeGitData) git(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = f.repo
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+func (f *fakeGitData) GetRef(ctx context.Context, owner, repo, ref string) (string, error) {
+	sha, err := f.git(nil, "rev-parse", "--verify", "--quiet", "refs/"+ref)
+	if err != nil {
+		return "", nil
+	}
+	return sha, nil
+}
+
+func (f *fakeGitData) GetTree(ctx context.Context, owner, repo, sha string) (*github.Tree, error) {
+	output, err := f.git(nil, "ls-tree", "-r", "-t", sha)
+	if err != nil {
+		return nil, err
+	}
+	tree := &github.Tree{SHA: stringPtr(sha)}
+	for _, line := range strings.Split(output, "\n") {
+		info, path, _ := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		tree.Entries = append(tree.Entries, &github.TreeEntry{
+			Path: stringPtr(path),
+			Mode: stringPtr(fields[0]),
+			Type: stringPtr(fields[1]),
+			SHA:  stringPtr(fields[2]),
+		})
+	}
+	return tree, nil
+}
+
+func (f *fakeGitData) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []*github.TreeEntry) (*github.Tree, error) {
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(f.t.TempDir(), "index")}
+	if _, err := f.git(env, "read-tree", baseTree); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		args := []string{"update-index", "--force-remove", entry.GetPath()}
+		if entry.SHA != nil {
+			args = []string{"update-index", "--add", "--cacheinfo", entry.GetMode() + "," + entry.GetSHA() + "," + entry.GetPath()}
+		}
+		if _, err := f.git(env, args...); err != nil {
+			return nil, err
+		}
+	}
+	sha, err := f.git(env, "write-tree")
+	return &github.Tree{SHA: &sha}, err
+}
+
+// CreateCommit attributes the commits without author nor committer to the
+// authenticated user, API Bot
+func (f *fakeGitData) CreateCommit(ctx context.Context, owner, repo string, commit *github.Commit) (*github.Commit, error) {
+	f.commits = append(f.commits, commit)
+	env := []string{
+		"GIT_AUTHOR_NAME=" + cmp.Or(commit.GetAuthor().GetName(), "API Bot"),
+		"GIT_AUTHOR_EMAIL=" + cmp.Or(commit.GetAuthor().GetEmail(), "api@test.com"),
+		"GIT_COMMITTER_NAME=" + cmp.Or(commit.GetCommitter().GetName(), "API Bot"),
+		"GIT_COMMITTER_EMAIL=" + cmp.Or(commit.GetCommitter().GetEmail(), "api@test.com"),
+	}
+	args := []string{"commit-tree", commit.GetTree().GetSHA(), "-m", commit.GetMessage()}
+	for _, parent := range commit.Parents {
+		args = append(args, "-p", parent.GetSHA())
+	}
+	sha, err := f.git(env, args...)
+	return &github.Commit{SHA: &sha}, err
+}
+
+func (f *fakeGitData) SetRef(ctx context.Context, owner, repo, ref, sha string, force bool) error {
+	if current, _ := f.GetRef(ctx, owner, repo, ref); current != "" && !force {
+		if _, err := f.git(nil, "merge-base", "--is-ancestor", current, sha); err != nil {
+			return fmt.Errorf("update is not a fast forward")
+		}
+	}
+	_, err := f.git(nil, "update-ref", "refs/"+ref, sha)
+	return err
+}
+
+func TestApplyChanges(t *testing.T) {
+	entry := func(sha string) *github.TreeEntry {
+		return &github.TreeEntry{Mode: stringPtr("100644"), Type: stringPtr("blob"), SHA: stringPtr(sha)}
+	}
+
+	tests := []struct {
+		name       string
+		target     map[string]*github.TreeEntry
+		wantErr    bool
+		wantEmpty  bool
+		wantPaths  []string
+		wantTarget map[string]string
+	}{
+		{
+			name:       "untouched on target",
+			target:     map[string]*github.TreeEntry{"changed": entry("a"), "removed": entry("r"), "other": entry("o")},
+			wantPaths:  []string{"added", "changed", "removed"},
+			wantTarget: map[string]string{"added": "n", "changed": "b", "other": "o"},
+		},
+		{
+			name:       "partly applied",
+			target:     map[string]*github.TreeEntry{"added": entry("n"), "changed": entry("a"), "removed": entry("r")},
+			wantPaths:  []string{"changed", "removed"},
+			wantTarget: map[string]string{"added": "n", "changed": "b"},
+		},
+		{
+			name:    "changed on target",
+			target:  map[string]*github.TreeEntry{"changed": entry("x"), "removed": entry("r")},
+			wantErr: true,
+		},
+		{
+			name:      "already applied",
+			target:    map[string]*github.TreeEntry{"added": entry("n"), "changed": entry("b")},
+			wantErr:   true,
+			wantEmpty: true,
+		},
+	}
+
+	before := map[string]*github.TreeEntry{"changed": entry("a"), "removed": entry("r"), "same": entry("s")}
+	after := map[string]*github.TreeEntry{"changed": entry("b"), "added": entry("n"), "same": entry("s")}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := applyChanges(tt.target, before, after)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errEmptyPick) != tt.wantEmpty {
+				t.Errorf("Expected errEmptyPick: %v, got %v", tt.wantEmpty, err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var paths []string
+			for _, e := range entries {
+				paths = append(paths, e.GetPath())
+				if (e.GetPath() == "removed") != (e.SHA == nil) {
+					t.Errorf("%s: unexpected SHA %v", e.GetPath(), e.SHA)
+				}
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("Expected entries for %v, got %v", tt.wantPaths, paths)
+			}
+
+			got := map[string]string{}
+			for path, e := range tt.target {
+				got[path] = e.GetSHA()
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantTarget) {
+				t.Errorf("Expected target %v, got %v", tt.wantTarget, got)
+			}
+		})
+	}
+}
+
+func TestPickMessage(t *testing.T) {
+	cfg := &Config{GitUserName: "Test Bot", GitUserEmail: "bot@test.com", Signoff: true, Trailers: []string{"Backport-Of: #1"}}
+	author := &github.CommitAuthor{Name: stringPtr("Author"), Email: stringPtr("author@test.com")}
+	trailers := "(cherry picked from commit abc)\nCo-authored-by: Author <author@test.com>\nSigned-off-by: Test Bot <bot@test.com>\nBackport-Of: #1\n"
+	coAuthored := "Fix things\n\nCo-authored-by: Author <author@test.com>\n"
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "Fix things\n", want: "Fix things\n\n" + trailers},
+		{message: "Fix things\n\nSigned-off-by: Author <author@test.com>\n", want: "Fix things\n\nSigned-off-by: Author <author@test.com>\n" + trailers},
+		{message: "Fix things\n\nBecause: reasons are long\nand wrap", want: "Fix things\n\nBecause: reasons are long\nand wrap\n\n" + trailers},
+		{message: coAuthored, want: coAuthored + strings.Replace(trailers, "Co-authored-by: Author <author@test.com>\n", "", 1)},
+	}
+
+	for _, tt := range tests {
+		if got := pickMessage(cfg, tt.message, "abc", author); got != tt.want {
+			t.Errorf("pickMessage(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestProcessBranch_APIRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodRebase)
+
+	// No local git at all
+	mockGit := &mockGitRunner{runFunc: func(cmd GitCommand) (GitOutput, error) {
+		t.Errorf("Unexpected git command: %v", cmd.Args)
+		return GitOutput{}, errors.New("no clone")
+	}}
+	gitData := &fakeGitData{t: t, repo: repos.origin}
+	service := NewService(newRealGitHubClient(t, repos), mockGit, WithGitDataClient(gitData))
+	cfg := &Config{
+		PRNumber:     1,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		Trailers:     []string{"Backport-Of: #1"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+	}
+
+	branch := "cherry-pick-1-to-release-v1.0"
+	if parent := gitRepo(t, repos.origin, "rev-parse", branch+"~2"); parent != gitRepo(t, repos.origin, "rev-parse", "release-v1.0") {
+		t.Errorf("Expected 2 picks on top of release-v1.0")
+	}
+	for file, want := range map[string]string{"VERSION": "release-v1.0", "fix.txt": "fix", "docs.txt": "docs"} {
+		if got := gitRepo(t, repos.origin, "show", branch+":"+file); got != want {
+			t.Errorf("Expected %s to be %q, got %q", file, want, got)
+		}
+	}
+	if _, err := exec.Command("git", "-C", repos.origin, "cat-file", "-e", branch+":NEWS").Output(); err == nil {
+		t.Error("Expected NEWS, which is not part of the PR, not to be picked")
+	}
+
+	// GitHub only signs the commits created without author nor committer
+	for _, commit := range gitData.commits {
+		if commit.Author != nil || commit.Committer != nil {
+			t.Errorf("Expected no author nor committer, got %+v and %+v", commit.Author, commit.Committer)
+		}
+	}
+	message := gitRepo(t, repos.origin, "log", "-1", "--format=%B", branch)
+	for _, want := range []string{"Document fix", "(cherry picked from commit " + repos.mergeCommit + ")", "Co-authored-by: Author <author@test.com>", "Backport-Of: #1"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected %q in the message, got:\n%s", want, message)
+		}
+	}
+}
+
+func TestProcessBranch_APIFallbackRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodMerge)
+	addConflicts(t, repos, "release-v1.0", "fix.txt")
+
+	// The fallback works from a shallow clone
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	gitRepo(t, repos.clone, "clone", "--depth=1", "file://"+repos.origin, shallow)
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: shallow}, WithGitDataClient(&fakeGitData{t: t, repo: repos.origin}))
+	cfg := &Config{
+		PRNumber:        1,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		StrategyOptions: []string{"theirs"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+	}
+
+	branch := "cherry-pick-1-to-release-v1.0"
+	if fix := gitRepo(t, repos.origin, "show", branch+":fix.txt"); fix != "fix" {
+		t.Errorf("Expected the picked side of fix.txt, got %q", fix)
+	}
+	if committer := gitRepo(t, repos.origin, "log", "-1", "--format=%cn", branch); committer != "Test Bot" {
+		t.Errorf("Expected the conflicting pick to be done with git, got committer %q", committer)
+	}
+}
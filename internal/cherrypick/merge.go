@@ -0,0 +1,155 @@
+package cherrypick
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// MergeMethod is the way a pull request was merged into its base branch
+type MergeMethod string
+
+const (
+	// MergeMethodMerge is a merge commit with the PR head as second parent
+	MergeMethodMerge MergeMethod = "merge"
+	// MergeMethodSquash is a single commit holding the whole PR. Rebased
+	// PRs with a single commit are reported as squashed as well.
+	MergeMethodSquash MergeMethod = "squash"
+	// MergeMethodRebase is one rebased commit per PR commit
+	MergeMethodRebase MergeMethod = "rebase"
+)
+
+// pickPlan describes the commits to cherry-pick, oldest first
+type pickPlan struct {
+	// pr is the PR picked, nil when picking commits
+	pr       *github.PullRequest
+	method   MergeMethod
+	commits  []string
+	mainline bool
+	// subjects holds the subject of each commit when picking commits
+	// directly rather than a PR.
+	subjects []string
+}
+
+// planPRPicks detects how the PR was merged from the merge commit's parents
+// and the PR's commit list.
+//
+// A merge commit is picked against its first parent. For a single-parent
+// merge commit, GitHub either squashed the PR or rebased its N commits, in
+// which case the merge commit is the last of them: the N first-parent
+// ancestors are compared with the PR commits and picked as a range when their
+// subjects match.
+func (s *Service) planPRPicks(ctx context.Context, cfg *Config, mergeCommit string) (*pickPlan, error) {
+	commit, err := s.github.GetCommit(ctx, cfg.RepoOwner, cfg.RepoName, mergeCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch merge commit %s: %w", mergeCommit, err)
+	}
+
+	if len(commit.Parents) > 1 {
+		return &pickPlan{method: MergeMethodMerge, commits: []string{mergeCommit}, mainline: true}, nil
+	}
+
+	squash := &pickPlan{method: MergeMethodSquash, commits: []string{mergeCommit}}
+
+	prCommits, err := s.github.ListPRCommits(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits of PR #%d: %w", cfg.PRNumber, err)
+	}
+
+	if len(prCommits) <= 1 {
+		return squash, nil
+	}
+
+	// Walk back from the merge commit, newest first
+	commits := make([]string, len(prCommits))
+	sha := mergeCommit
+	for i := len(prCommits) - 1; i >= 0; i-- {
+		if subject(commit.GetMessage()) != subject(prCommits[i].GetCommit().GetMessage()) {
+			return squash, nil
+		}
+		commits[i] = sha
+
+		if i == 0 {
+			break
+		}
+		if len(commit.Parents) != 1 {
+			return squash, nil
+		}
+		sha = commit.Parents[0].GetSHA()
+		if commit, err = s.github.GetCommit(ctx, cfg.RepoOwner, cfg.RepoName, sha); err != nil {
+			return nil, fmt.Errorf("failed to fetch commit %s: %w", sha, err)
+		}
+	}
+
+	return &pickPlan{method: MergeMethodRebase, commits: commits}, nil
+}
+
+// subject returns the first line of a commit message
+func subject(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return strings.TrimSpace(line)
+}
+
+// branchName returns the name of the branch holding the cherry-pick. The
+// branch of a PR pick is rendered from cfg.BranchTemplate.
+func (p *pickPlan) branchName(cfg *Config, targetBranch string) (string, error) {
+	if len(cfg.Commits) == 0 {
+		name, err := executeTemplate("branch", cfg.BranchTemplate, DefaultBranchTemplate, newTemplateData(cfg, p.pr, targetBranch))
+		if err != nil {
+			return "", err
+		}
+		name = strings.TrimSpace(name)
+		return name, validateBranchName(name)
+	}
+
+	first, last := shortSHA(p.commits[0]), shortSHA(p.commits[len(p.commits)-1])
+	if first == last {
+		return fmt.Sprintf("cherry-pick-%s-to-%s", first, targetBranch), nil
+	}
+	return fmt.Sprintf("cherry-pick-%s-%s-to-%s", first, last, targetBranch), nil
+}
+
+// describe returns the title and body of the cherry-pick PR. Those of a PR
+// pick are rendered from cfg.TitleTemplate and cfg.BodyTemplate.
+func (p *pickPlan) describe(cfg *Config, targetBranch string) (string, string, error) {
+	if len(cfg.Commits) == 0 {
+		data := newTemplateData(cfg, p.pr, targetBranch)
+		title, err := executeTemplate("title", cfg.TitleTemplate, DefaultTitleTemplate, data)
+		if err != nil {
+			return "", "", err
+		}
+		// Titles are a single line
+		title = strings.Join(strings.Fields(title), " ")
+		if title == "" {
+			return "", "", fmt.Errorf("the title template rendered an empty title")
+		}
+		body, err := executeTemplate("body", cfg.BodyTemplate, DefaultBodyTemplate, data)
+		if err != nil {
+			return "", "", err
+		}
+		return title, truncateBody(body), nil
+	}
+
+	title := fmt.Sprintf("Cherry-pick %s to %s", shortSHA(p.commits[0]), targetBranch)
+	if len(p.commits) > 1 {
+		title = fmt.Sprintf("Cherry-pick %d commits to %s", len(p.commits), targetBranch)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Automatic cherry-pick of the following commits to `%s`:\n\n", targetBranch)
+	for i, sha := range p.commits {
+		fmt.Fprintf(&body, "- [`%s`](https://github.com/%s/%s/commit/%s) %s\n",
+			shortSHA(sha), cfg.RepoOwner, cfg.RepoName, sha, p.subjects[i])
+	}
+	return title, body.String(), nil
+}
+
+// shortSHA abbreviates a commit SHA the way GitHub displays it
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
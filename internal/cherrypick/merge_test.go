@@ -0,0 +1,136 @@
+package cherrypick
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestPlanPRPicks(t *testing.T) {
+	// History: c1 <- c2 <- c3 (merge commit candidate)
+	commits := map[string]*github.Commit{
+		"c1": {SHA: stringPtr("c1"), Message: stringPtr("First change"), Parents: []*github.Commit{{SHA: stringPtr("c0")}}},
+		"c2": {SHA: stringPtr("c2"), Message: stringPtr("Second change\n\nDetails"), Parents: []*github.Commit{{SHA: stringPtr("c1")}}},
+		"c3": {SHA: stringPtr("c3"), Message: stringPtr("Third change"), Parents: []*github.Commit{{SHA: stringPtr("c2")}}},
+		"m": {SHA: stringPtr("m"), Message: stringPtr("Merge pull request #123"), Parents: []*github.Commit{
+			{SHA: stringPtr("c3")}, {SHA: stringPtr("head")},
+		}},
+	}
+
+	prCommit := func(message string) *github.RepositoryCommit {
+		return &github.RepositoryCommit{Commit: &github.Commit{Message: stringPtr(message)}}
+	}
+
+	tests := []struct {
+		name        string
+		mergeCommit string
+		prCommits   []*github.RepositoryCommit
+		want        *pickPlan
+	}{
+		{
+			name:        "merge commit",
+			mergeCommit: "m",
+			want:        &pickPlan{method: MergeMethodMerge, commits: []string{"m"}, mainline: true},
+		},
+		{
+			name:        "single commit",
+			mergeCommit: "c3",
+			prCommits:   []*github.RepositoryCommit{prCommit("Something else")},
+			want:        &pickPlan{method: MergeMethodSquash, commits: []string{"c3"}},
+		},
+		{
+			name:        "squashed",
+			mergeCommit: "c3",
+			prCommits:   []*github.RepositoryCommit{prCommit("wip"), prCommit("Third change")},
+			want:        &pickPlan{method: MergeMethodSquash, commits: []string{"c3"}},
+		},
+		{
+			name:        "rebased",
+			mergeCommit: "c3",
+			prCommits: []*github.RepositoryCommit{
+				prCommit("Second change\n\nOriginal details"),
+				prCommit("Third change"),
+			},
+			want: &pickPlan{method: MergeMethodRebase, commits: []string{"c2", "c3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGH := &mockGitHubClient{
+				getCommit: func(ctx context.Context, owner, repo, sha string) (*github.Commit, error) {
+					return commits[sha], nil
+				},
+				listPRCommits: func(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+					return tt.prCommits, nil
+				},
+			}
+
+			service := NewService(mockGH, &mockGitRunner{})
+			cfg := &Config{PRNumber: 123, RepoOwner: "owner", RepoName: "repo"}
+
+			plan, err := service.planPRPicks(context.Background(), cfg, tt.mergeCommit)
+			if err != nil {
+				t.Fatalf("planPRPicks() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(plan, tt.want) {
+				t.Errorf("planPRPicks() = %+v, want %+v", plan, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessBranch_MergeMethodsRealGit(t *testing.T) {
+	tests := []struct {
+		method      MergeMethod
+		wantCommits int
+	}{
+		{method: MergeMethodMerge, wantCommits: 1},
+		{method: MergeMethodSquash, wantCommits: 1},
+		{method: MergeMethodRebase, wantCommits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			repos := setupRealRepos(t, []string{"release-v1.0"}, tt.method)
+			service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone})
+
+			cfg := &Config{
+				PRNumber:     1,
+				RepoOwner:    "owner",
+				RepoName:     "repo",
+				GitUserName:  "Test Bot",
+				GitUserEmail: "bot@test.com",
+			}
+
+			result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+			if !result.Success {
+				t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+			}
+
+			if result.MergeMethod != tt.method {
+				t.Errorf("Expected merge method %q, got %q", tt.method, result.MergeMethod)
+			}
+
+			picked := strings.Fields(gitRepo(t, repos.origin, "rev-list", "release-v1.0..cherry-pick-1-to-release-v1.0"))
+			if len(picked) != tt.wantCommits {
+				t.Errorf("Expected %d picked commits, got %d", tt.wantCommits, len(picked))
+			}
+
+			// The whole PR must be there, and nothing else from main
+			files := gitRepo(t, repos.origin, "ls-tree", "--name-only", "cherry-pick-1-to-release-v1.0")
+			for _, file := range []string{"fix.txt", "docs.txt", "VERSION"} {
+				if !strings.Contains(files, file) {
+					t.Errorf("Expected %s on the cherry-pick branch, got:\n%s", file, files)
+				}
+			}
+			if strings.Contains(files, "NEWS") {
+				t.Errorf("Unexpected unrelated main change on the cherry-pick branch")
+			}
+		})
+	}
+}
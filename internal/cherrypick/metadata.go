@@ -0,0 +1,157 @@
+package cherrypick
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// AddAssignees assigns users to an issue or pull request
+func (c *DefaultGitHubClient) AddAssignees(ctx context.Context, owner, repo string, number int, assignees []string) error {
+	_, _, err := c.client.Issues.AddAssignees(ctx, owner, repo, number, assignees)
+	return err
+}
+
+// SetMilestone sets the open milestone with the given title on an issue or
+// pull request
+func (c *DefaultGitHubClient) SetMilestone(ctx context.Context, owner, repo string, number int, title string) error {
+	opts := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := c.client.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil {
+			return err
+		}
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				_, _, err := c.client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{Milestone: milestone.Number})
+				return err
+			}
+		}
+		if resp.NextPage == 0 {
+			return fmt.Errorf("no open milestone %q", title)
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// copyMetadata labels, assigns and sets the milestone of pr, the cherry-pick
+// PR of source to result.Branch, adding labels to the labels copied from
+// source. Source is nil when picking commits. Failures do not undo the
+// cherry-pick: they are only reported as warnings of result.
+func (s *Service) copyMetadata(ctx context.Context, cfg *Config, result *Result, source, pr *github.PullRequest, labels ...string) {
+	owner, repo, number := cfg.RepoOwner, cfg.RepoName, pr.GetNumber()
+
+	if source != nil {
+		var sourceLabels []string
+		for _, label := range source.Labels {
+			sourceLabels = append(sourceLabels, label.GetName())
+		}
+		labels = append(cfg.copiedLabels(sourceLabels), labels...)
+	}
+	if len(labels) > 0 {
+		if err := s.github.AddLabels(ctx, owner, repo, number, labels); err != nil {
+			result.warn("failed to label #%d: %v", number, err)
+		}
+	}
+
+	if milestone := cfg.milestone(result.Branch); milestone != "" {
+		if err := s.github.SetMilestone(ctx, owner, repo, number, milestone); err != nil {
+			result.warn("failed to set milestone %q on #%d: %v", milestone, number, err)
+		}
+	}
+
+	if author := source.GetUser().GetLogin(); cfg.AssignAuthor && author != "" {
+		if err := s.github.AddAssignees(ctx, owner, repo, number, []string{author}); err != nil {
+			result.warn("failed to assign @%s to #%d: %v", author, number, err)
+		}
+	}
+}
+
+// copiedLabels returns the labels of the source PR to put on its cherry-pick
+// PRs: those matching cfg.LabelAllow, if any, and none of cfg.LabelDeny,
+// renamed with cfg.LabelRename. The deferred cherry-pick labels are never
+// copied.
+func (cfg *Config) copiedLabels(labels []string) []string {
+	var copied []string
+	for _, label := range labels {
+		if strings.HasPrefix(label, DeferredLabelPrefix) {
+			continue
+		}
+		if len(cfg.LabelAllow) > 0 && !matchesAny(cfg.LabelAllow, label) || matchesAny(cfg.LabelDeny, label) {
+			continue
+		}
+		if renamed, ok := cfg.LabelRename[label]; ok {
+			label = renamed
+		}
+		if !slices.Contains(copied, label) {
+			copied = append(copied, label)
+		}
+	}
+	return copied
+}
+
+// milestone returns the milestone of the cherry-picks to targetBranch: the one
+// of the branch itself, or else of the longest, most specific, pattern
+// matching it
+func (cfg *Config) milestone(targetBranch string) string {
+	if milestone, ok := cfg.BranchMilestones[targetBranch]; ok {
+		return milestone
+	}
+	var patterns []string
+	for pattern := range cfg.BranchMilestones {
+		patterns = append(patterns, pattern)
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, targetBranch); ok {
+			return cfg.BranchMilestones[pattern]
+		}
+	}
+	return ""
+}
+
+// validateLabels checks the label patterns and renames of the configuration
+func validateLabels(allow, deny []string, rename map[string]string) error {
+	for _, pattern := range append(slices.Clone(allow), deny...) {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("invalid label pattern %q", pattern)
+		}
+	}
+	for from, to := range rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("invalid label rename %q to %q", from, to)
+		}
+	}
+	return nil
+}
+
+// validateMilestones checks the branch patterns and milestones of the
+// configuration
+func validateMilestones(milestones map[string]string) error {
+	for pattern, milestone := range milestones {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("invalid milestone branch pattern %q", pattern)
+		}
+		if milestone == "" {
+			return fmt.Errorf("empty milestone for %s", pattern)
+		}
+	}
+	return nil
+}
+
+// matchesAny reports whether name matches one of patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
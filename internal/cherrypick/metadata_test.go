@@ -0,0 +1,231 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestCopiedLabels(t *testing.T) {
+	labels := []string{"kind/bug", "kind/feature", "area/cli", "lgtm", "approved", "needs-rebase"}
+	// The deferred cherry-pick labels are left out
+	source := append(slices.Clone(labels), "cherry-pick/release-v1.0")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "all labels",
+			want: labels,
+		},
+		{
+			name: "allowed labels",
+			cfg:  Config{LabelAllow: []string{"kind/*", "area/*"}},
+			want: []string{"kind/bug", "kind/feature", "area/cli"},
+		},
+		{
+			name: "denied labels",
+			cfg:  Config{LabelDeny: []string{"lgtm", "approved", "needs-*"}},
+			want: []string{"kind/bug", "kind/feature", "area/cli"},
+		},
+		{
+			name: "allowed but denied",
+			cfg:  Config{LabelAllow: []string{"kind/*"}, LabelDeny: []string{"kind/feature"}},
+			want: []string{"kind/bug"},
+		},
+		{
+			name: "renamed labels",
+			cfg:  Config{LabelAllow: []string{"kind/*"}, LabelRename: map[string]string{"kind/bug": "backport/bug"}},
+			want: []string{"backport/bug", "kind/feature"},
+		},
+		{
+			name: "renamed onto another label",
+			cfg:  Config{LabelAllow: []string{"kind/*"}, LabelRename: map[string]string{"kind/feature": "kind/bug"}},
+			want: []string{"kind/bug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.copiedLabels(source); !slices.Equal(got, tt.want) {
+				t.Errorf("copiedLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMilestone(t *testing.T) {
+	cfg := &Config{
+		BranchMilestones: map[string]string{
+			"release-v1.2.x": "v1.2.1",
+			"release-v1.*":   "v1.x",
+			"release-v*":     "next",
+		},
+	}
+
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"release-v1.2.x", "v1.2.1"},
+		{"release-v1.3.x", "v1.x"},
+		{"release-v2.0.x", "next"},
+		{"main", ""},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.milestone(tt.branch); got != tt.want {
+			t.Errorf("milestone(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+// metadataGitHubClient returns a client cherry-picking a merged PR with
+// labels, recording the metadata set on the created PR #789
+func metadataGitHubClient(t *testing.T, labels, assignees *[]string, milestone *string) *mockGitHubClient {
+	return &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{
+				Merged:         boolPtr(true),
+				MergeCommitSHA: stringPtr("abc123"),
+				User:           &github.User{Login: stringPtr("author")},
+				Labels: []*github.Label{
+					{Name: stringPtr("kind/bug")},
+					{Name: stringPtr("lgtm")},
+				},
+			}, nil
+		},
+		findExistingPR: func(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+			return nil, nil
+		},
+		createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+			return &github.PullRequest{Number: intPtr(789)}, nil
+		},
+		addLabels: func(ctx context.Context, owner, repo string, number int, l []string) error {
+			if number != 789 {
+				t.Errorf("Expected labels on #789, got #%d", number)
+			}
+			*labels = l
+			return nil
+		},
+		addAssignees: func(ctx context.Context, owner, repo string, number int, a []string) error {
+			if number != 789 {
+				t.Errorf("Expected assignees on #789, got #%d", number)
+			}
+			*assignees = a
+			return nil
+		},
+		setMilestone: func(ctx context.Context, owner, repo string, number int, title string) error {
+			if number != 789 {
+				t.Errorf("Expected a milestone on #789, got #%d", number)
+			}
+			*milestone = title
+			return nil
+		},
+	}
+}
+
+func TestProcessBranch_Metadata(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	service := NewService(metadataGitHubClient(t, &labels, &assignees, &milestone), &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:         123,
+		RepoOwner:        "owner",
+		RepoName:         "repo",
+		GitUserName:      "Test Bot",
+		GitUserEmail:     "bot@test.com",
+		LabelDeny:        []string{"lgtm"},
+		BranchMilestones: map[string]string{"release-*": "v1.0.x"},
+		AssignAuthor:     true,
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+	}
+	if len(result.Warnings) > 0 {
+		t.Errorf("Expected no warnings, got %v", result.Warnings)
+	}
+
+	if !slices.Equal(labels, []string{"kind/bug"}) {
+		t.Errorf("Expected the kind/bug label, got %v", labels)
+	}
+	if milestone != "v1.0.x" {
+		t.Errorf("Expected the v1.0.x milestone, got %q", milestone)
+	}
+	if !slices.Equal(assignees, []string{"author"}) {
+		t.Errorf("Expected the author to be assigned, got %v", assignees)
+	}
+}
+
+func TestProcessBranch_MetadataDisabled(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	service := NewService(metadataGitHubClient(t, &labels, &assignees, &milestone), &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:     123,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		LabelAllow:   []string{"area/*"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+	}
+	if labels != nil || assignees != nil || milestone != "" {
+		t.Errorf("Expected no metadata, got labels %v, assignees %v and milestone %q", labels, assignees, milestone)
+	}
+}
+
+func TestProcessBranch_MetadataFailures(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	mockGH := metadataGitHubClient(t, &labels, &assignees, &milestone)
+	mockGH.setMilestone = func(ctx context.Context, owner, repo string, number int, title string) error {
+		return errors.New(`no open milestone "v1.0.x"`)
+	}
+	mockGH.addAssignees = func(ctx context.Context, owner, repo string, number int, a []string) error {
+		return errors.New("validation failed")
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:         123,
+		RepoOwner:        "owner",
+		RepoName:         "repo",
+		GitUserName:      "Test Bot",
+		GitUserEmail:     "bot@test.com",
+		BranchMilestones: map[string]string{"release-1.0": "v1.0.x"},
+		AssignAuthor:     true,
+	}
+
+	// The PR is opened all the same
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success || result.NewPR.GetNumber() != 789 {
+		t.Fatalf("Expected PR #789 to be created, got %+v (%s)", result.NewPR, result.ErrorMessage)
+	}
+	if !slices.Equal(labels, []string{"kind/bug", "lgtm"}) {
+		t.Errorf("Expected all the labels to be copied, got %v", labels)
+	}
+
+	if len(result.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", result.Warnings)
+	}
+	for i, want := range []string{`failed to set milestone "v1.0.x" on #789`, "failed to assign @author to #789"} {
+		if !strings.HasPrefix(result.Warnings[i], want) {
+			t.Errorf("Warning %d: expected %q, got %q", i, want, result.Warnings[i])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cherrypick
+
+import (
+	"context"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Stage is a step of the cherry-pick to a single branch
+type Stage string
+
+const (
+	StageFetching Stage = "fetching"
+	StagePicking  Stage = "picking"
+	StagePushing  Stage = "pushing"
+	StagePROpened Stage = "pr-opened"
+	// StageDone is reported last, whatever the outcome
+	StageDone Stage = "done"
+)
+
+// Event reports the progress of the cherry-pick to a single branch
+type Event struct {
+	Branch string
+	Stage  Stage
+	// PR is the opened pull request, set for StagePROpened
+	PR *github.PullRequest
+	// Result is the final result, set for StageDone
+	Result *Result
+}
+
+// ProgressReporter receives events while branches are processed. It is
+// called concurrently from the goroutines of ProcessBranches.
+type ProgressReporter interface {
+	ReportProgress(ctx context.Context, event Event)
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithProgressReporter makes the service report per-branch events to r
+func WithProgressReporter(r ProgressReporter) Option {
+	return func(s *Service) {
+		s.progress = r
+	}
+}
+
+// report sends event to the progress reporter, if any
+func (s *Service) report(ctx context.Context, event Event) {
+	if s.progress != nil {
+		s.progress.ReportProgress(ctx, event)
+	}
+}
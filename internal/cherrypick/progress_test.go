@@ -0,0 +1,85 @@
+package cherrypick
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingReporter) ReportProgress(ctx context.Context, event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingReporter) stages(branch string) []Stage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stages []Stage
+	for _, event := range r.events {
+		if event.Branch == branch {
+			stages = append(stages, event.Stage)
+		}
+	}
+	return stages
+}
+
+func TestProcessBranches_ReportsProgress(t *testing.T) {
+	mockGH := &mockGitHubClient{
+		getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+			return &github.PullRequest{
+				Merged:         boolPtr(true),
+				MergeCommitSHA: stringPtr("abc123"),
+			}, nil
+		},
+		createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+			return &github.PullRequest{Number: intPtr(1)}, nil
+		},
+	}
+
+	mockGit := &mockGitRunner{
+		runFunc: func(cmd GitCommand) (GitOutput, error) {
+			if cmd.Args[0] == "fetch" && cmd.Args[len(cmd.Args)-1] == "+refs/heads/missing:refs/remotes/origin/missing" {
+				return GitOutput{}, &GitError{Args: cmd.Args}
+			}
+			return GitOutput{}, nil
+		},
+	}
+
+	reporter := &recordingReporter{}
+	service := NewService(mockGH, mockGit, WithProgressReporter(reporter))
+
+	cfg := &Config{
+		PRNumber:  123,
+		Branches:  []string{"release-1.0", "missing"},
+		RepoOwner: "owner",
+		RepoName:  "repo",
+	}
+
+	results := service.ProcessBranches(context.Background(), cfg)
+
+	want := []Stage{StageFetching, StagePicking, StagePushing, StagePROpened, StageDone}
+	if got := reporter.stages("release-1.0"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected stages %v, got %v", want, got)
+	}
+
+	want = []Stage{StageFetching, StageDone}
+	if got := reporter.stages("missing"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected stages %v, got %v", want, got)
+	}
+
+	// The done event carries the final result
+	for _, event := range reporter.events {
+		if event.Stage == StageDone && event.Result != results[0] && event.Result != results[1] {
+			t.Errorf("Expected the done event of %s to carry its result", event.Branch)
+		}
+	}
+}
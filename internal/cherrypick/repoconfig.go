@@ -0,0 +1,179 @@
+package cherrypick
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigPath is the path of the repository configuration, read from the
+// default branch
+const RepoConfigPath = ".github/cherry-pick.yaml"
+
+// RepoConfig is the repository configuration of the cherry-picks. Its options
+// are defaults that the command line can override.
+type RepoConfig struct {
+	// Bot is the identity committing the picks
+	Bot struct {
+		Name  string `yaml:"name"`
+		Email string `yaml:"email"`
+	} `yaml:"bot"`
+	// Branches are the patterns of the branches that can be cherry-picked
+	// to. Any branch can when empty.
+	Branches []string `yaml:"branches"`
+	// Aliases are named lists of branch patterns usable as targets
+	Aliases map[string][]string `yaml:"aliases"`
+	// StrategyOptions are the merge strategy options of target branches
+	StrategyOptions map[string][]string `yaml:"strategy-options"`
+	// Concurrency is the number of branches processed at once, 0 for all
+	Concurrency     int      `yaml:"concurrency"`
+	DraftOnConflict bool     `yaml:"draft-on-conflict"`
+	Signoff         bool     `yaml:"signoff"`
+	Trailers        []string `yaml:"trailers"`
+	// Templates of the cherry-pick branches and PRs, see TemplateData
+	Templates struct {
+		Branch string `yaml:"branch"`
+		Title  string `yaml:"title"`
+		Body   string `yaml:"body"`
+	} `yaml:"templates"`
+	// Labels select the labels of the source PR copied to the cherry-pick
+	// PRs, see Config.LabelAllow
+	Labels struct {
+		Allow  []string          `yaml:"allow"`
+		Deny   []string          `yaml:"deny"`
+		Rename map[string]string `yaml:"rename"`
+	} `yaml:"labels"`
+	// Milestones are the milestones of the cherry-pick PRs by target branch
+	// pattern
+	Milestones map[string]string `yaml:"milestones"`
+	// AssignAuthor assigns the cherry-pick PRs to the author of the source
+	// PR, which is the default
+	AssignAuthor *bool `yaml:"assign-author"`
+	// RequestReviews requests reviews from the author and the approvers of
+	// the source PR, which is the default
+	RequestReviews *bool `yaml:"request-reviews"`
+	// Reviewers are the reviewers of the cherry-pick PRs by target branch
+	// pattern, logins or org/team
+	Reviewers map[string][]string `yaml:"reviewers"`
+	// AutoMerge is the method clean cherry-pick PRs are auto-merged with:
+	// merge, squash or rebase. Auto-merge is disabled when empty.
+	AutoMerge MergeMethod `yaml:"auto-merge"`
+}
+
+// FileClient reads files from repositories
+type FileClient interface {
+	// GetFile returns the content of path at ref, the default branch when
+	// ref is empty, or nil if there is no such file
+	GetFile(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+}
+
+func (c *DefaultGitHubClient) GetFile(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	file, _, resp, err := c.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	content, err := file.GetContent()
+	return []byte(content), err
+}
+
+// LoadRepoConfig reads and validates the configuration of a repository from
+// its default branch. It returns nil when the repository has none.
+func LoadRepoConfig(ctx context.Context, client FileClient, owner, repo string) (*RepoConfig, error) {
+	data, err := client.GetFile(ctx, owner, repo, RepoConfigPath, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", RepoConfigPath, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	rc, err := ParseRepoConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", RepoConfigPath, err)
+	}
+	return rc, nil
+}
+
+// ParseRepoConfig parses and validates a repository configuration. Unknown
+// options are errors, so that typos do not go unnoticed.
+func ParseRepoConfig(data []byte) (*RepoConfig, error) {
+	rc := &RepoConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file is a valid empty configuration
+	if err := decoder.Decode(rc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := rc.Validate(); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// Validate checks the options of the configuration, reporting all the
+// invalid ones
+func (rc *RepoConfig) Validate() error {
+	var errs []error
+	invalid := func(option string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", option, err))
+	}
+
+	if rc.Bot.Email != "" && !strings.Contains(rc.Bot.Email, "@") {
+		invalid("bot.email", fmt.Errorf("invalid email %q", rc.Bot.Email))
+	}
+	for _, pattern := range rc.Branches {
+		if _, latest, err := parseBranchPattern(pattern); err != nil {
+			invalid("branches", err)
+		} else if latest > 0 {
+			invalid("branches", fmt.Errorf("invalid branch pattern %q: counts are only allowed in aliases", pattern))
+		}
+	}
+	for alias, patterns := range rc.Aliases {
+		if err := validateAlias(alias, patterns); err != nil {
+			invalid("aliases", err)
+		}
+	}
+	for branch, options := range rc.StrategyOptions {
+		for _, option := range options {
+			if err := validateStrategyOption(option); err != nil {
+				invalid("strategy-options."+branch, err)
+			}
+		}
+	}
+	if rc.Concurrency < 0 {
+		invalid("concurrency", fmt.Errorf("must be positive, or 0 for no limit, got %d", rc.Concurrency))
+	}
+	for _, trailer := range rc.Trailers {
+		if err := validateTrailer(trailer); err != nil {
+			invalid("trailers", err)
+		}
+	}
+	if err := validateTemplates(rc.Templates.Branch, rc.Templates.Title, rc.Templates.Body); err != nil {
+		invalid("templates", err)
+	}
+	if err := validateLabels(rc.Labels.Allow, rc.Labels.Deny, rc.Labels.Rename); err != nil {
+		invalid("labels", err)
+	}
+	if err := validateMilestones(rc.Milestones); err != nil {
+		invalid("milestones", err)
+	}
+	if err := validateReviewers(rc.Reviewers); err != nil {
+		invalid("reviewers", err)
+	}
+	if err := validateAutoMerge(rc.AutoMerge); err != nil {
+		invalid("auto-merge", err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type mockFileClient struct {
+	files map[string]string
+	err   error
+}
+
+func (m *mockFileClient) GetFile(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	content, ok := m.files[path]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(content), nil
+}
+
+func TestParseRepoConfig(t *testing.T) {
+	data := `
+bot:
+  name: Release Bot
+  email: release-bot@test.com
+branches:
+  - release-v*
+aliases:
+  supported: [release-v1.*, release-v2.*]
+  latest-two: [release-v*:2]
+strategy-options:
+  release-v1.0: [theirs]
+concurrency: 2
+draft-on-conflict: true
+signoff: true
+trailers:
+  - "Backported-By: release bot"
+templates:
+  title: "[{{.Target}}] {{.Title}}"
+labels:
+  deny: [lgtm, approved]
+  rename:
+    kind/bug: backport/bug
+milestones:
+  release-v1.*: v1.x
+assign-author: false
+request-reviews: false
+auto-merge: squash
+reviewers:
+  release-v1.*: [org/release-team]
+`
+	rc, err := ParseRepoConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseRepoConfig() error = %v", err)
+	}
+
+	if rc.Bot.Name != "Release Bot" || rc.Bot.Email != "release-bot@test.com" {
+		t.Errorf("Unexpected bot %+v", rc.Bot)
+	}
+	if !slices.Equal(rc.Branches, []string{"release-v*"}) {
+		t.Errorf("Unexpected branches %v", rc.Branches)
+	}
+	if !slices.Equal(rc.Aliases["latest-two"], []string{"release-v*:2"}) || len(rc.Aliases) != 2 {
+		t.Errorf("Unexpected aliases %v", rc.Aliases)
+	}
+	if !slices.Equal(rc.StrategyOptions["release-v1.0"], []string{"theirs"}) {
+		t.Errorf("Unexpected strategy options %v", rc.StrategyOptions)
+	}
+	if rc.Templates.Title != "[{{.Target}}] {{.Title}}" {
+		t.Errorf("Unexpected templates %+v", rc.Templates)
+	}
+	if !slices.Equal(rc.Labels.Deny, []string{"lgtm", "approved"}) || rc.Labels.Rename["kind/bug"] != "backport/bug" {
+		t.Errorf("Unexpected labels %+v", rc.Labels)
+	}
+	if rc.Milestones["release-v1.*"] != "v1.x" {
+		t.Errorf("Unexpected milestones %v", rc.Milestones)
+	}
+	if rc.AssignAuthor == nil || *rc.AssignAuthor {
+		t.Errorf("Expected assign-author to be disabled, got %v", rc.AssignAuthor)
+	}
+	if rc.RequestReviews == nil || *rc.RequestReviews || !slices.Equal(rc.Reviewers["release-v1.*"], []string{"org/release-team"}) {
+		t.Errorf("Unexpected reviews %v and %v", rc.RequestReviews, rc.Reviewers)
+	}
+	if rc.AutoMerge != MergeMethodSquash {
+		t.Errorf("Unexpected auto-merge %q", rc.AutoMerge)
+	}
+	if rc.Concurrency != 2 || !rc.DraftOnConflict || !rc.Signoff || len(rc.Trailers) != 1 {
+		t.Errorf("Unexpected options %+v", rc)
+	}
+}
+
+func TestParseRepoConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "unknown option",
+			data: "bot:\n  nmae: Release Bot\n",
+			want: []string{"line 2", "nmae"},
+		},
+		{
+			name: "wrong type",
+			data: "concurrency: many\n",
+			want: []string{"line 1", "many"},
+		},
+		{
+			name: "invalid values",
+			data: `
+bot:
+  email: release-bot
+branches: ["release-[v"]
+aliases:
+  "release-*": [main]
+strategy-options:
+  main: [mine]
+concurrency: -1
+trailers: [Backported by the bot]
+templates:
+  title: "{{.Title"
+labels:
+  allow: ["kind/["]
+milestones:
+  release-v1.*: ""
+reviewers:
+  release-v1.*: ["@alice"]
+auto-merge: yes
+`,
+			want: []string{
+				`bot.email: invalid email "release-bot"`,
+				`branches: invalid branch pattern "release-[v"`,
+				`aliases: invalid branch alias "release-*"`,
+				`strategy-options.main: invalid strategy option "mine"`,
+				"concurrency: must be positive",
+				`trailers: invalid trailer "Backported by the bot"`,
+				"templates: invalid title template",
+				`labels: invalid label pattern "kind/["`,
+				"milestones: empty milestone for release-v1.*",
+				`reviewers: invalid reviewer "@alice" for release-v1.*`,
+				`auto-merge: invalid auto-merge method "yes"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRepoConfig([]byte(tt.data))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected %q in error:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRepoConfig(t *testing.T) {
+	ctx := context.Background()
+
+	rc, err := LoadRepoConfig(ctx, &mockFileClient{}, "owner", "repo")
+	if rc != nil || err != nil {
+		t.Errorf("Expected no configuration without a file, got %+v, %v", rc, err)
+	}
+
+	rc, err = LoadRepoConfig(ctx, &mockFileClient{files: map[string]string{RepoConfigPath: ""}}, "owner", "repo")
+	if rc == nil || err != nil {
+		t.Errorf("Expected an empty configuration for an empty file, got %+v, %v", rc, err)
+	}
+
+	_, err = LoadRepoConfig(ctx, &mockFileClient{files: map[string]string{RepoConfigPath: "concurrency: -1\n"}}, "owner", "repo")
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid "+RepoConfigPath+": ") {
+		t.Errorf("Expected the file to be named in the error, got %v", err)
+	}
+
+	_, err = LoadRepoConfig(ctx, &mockFileClient{err: errors.New("boom")}, "owner", "repo")
+	if err == nil {
+		t.Error("Expected an error when the file cannot be read")
+	}
+}
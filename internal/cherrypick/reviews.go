@@ -0,0 +1,184 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// ListReviews returns the reviews of a pull request, oldest first
+func (c *DefaultGitHubClient) ListReviews(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+	var all []*github.PullRequestReview
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, reviews...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// RequestReviewers requests reviews of a pull request from users and from
+// teams, given by their slug
+func (c *DefaultGitHubClient) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers, teams []string) error {
+	_, _, err := c.client.PullRequests.RequestReviewers(ctx, owner, repo, number, github.ReviewersRequest{
+		Reviewers:     reviewers,
+		TeamReviewers: teams,
+	})
+	return err
+}
+
+// requestReviews requests reviews of pr, the cherry-pick PR of source to
+// result.Branch, from the author and approvers of source when
+// cfg.RequestReviews is set, and from the reviewers of the branch. Source is
+// nil when picking commits. Failures are only reported as warnings of result.
+func (s *Service) requestReviews(ctx context.Context, cfg *Config, result *Result, source, pr *github.PullRequest) {
+	var reviewers []string
+	if cfg.RequestReviews && source != nil {
+		if author := source.GetUser(); author.GetLogin() != "" && !isBot(author) {
+			reviewers = append(reviewers, author.GetLogin())
+		}
+		reviews, err := s.github.ListReviews(ctx, cfg.RepoOwner, cfg.RepoName, cfg.PRNumber)
+		if err != nil {
+			result.warn("failed to list the reviews of #%d: %v", cfg.PRNumber, err)
+		}
+		reviewers = append(reviewers, approvers(reviews)...)
+	}
+
+	// The reviewers of the branch are requested on their own, so that a stale
+	// entry of the configuration does not prevent the other requests
+	var requested []string
+	s.requestReviewers(ctx, cfg, result, pr.GetNumber(), reviewers, &requested)
+	s.requestReviewers(ctx, cfg, result, pr.GetNumber(), cfg.branchReviewers(result.Branch), &requested)
+}
+
+// requestReviewers requests reviews of PR number from the reviewers, logins or
+// org/team, not in requested yet, and adds them to it. GitHub rejects the
+// whole request when one of them cannot review, e.g. a login that is not a
+// collaborator: each reviewer is then requested on its own.
+func (s *Service) requestReviewers(ctx context.Context, cfg *Config, result *Result, number int, reviewers []string, requested *[]string) {
+	var users, teams []string
+	for _, reviewer := range reviewers {
+		if slices.ContainsFunc(*requested, func(r string) bool { return strings.EqualFold(r, reviewer) }) {
+			continue
+		}
+		*requested = append(*requested, reviewer)
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			teams = append(teams, team)
+		} else {
+			users = append(users, reviewer)
+		}
+	}
+	if len(users) == 0 && len(teams) == 0 {
+		return
+	}
+
+	log.Printf("Requesting reviews of #%d from %s", number, strings.Join(append(slices.Clone(users), teams...), ", "))
+	err := s.github.RequestReviewers(ctx, cfg.RepoOwner, cfg.RepoName, number, users, teams)
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusUnprocessableEntity ||
+		len(users)+len(teams) == 1 {
+		if err != nil {
+			result.warn("failed to request reviews of #%d: %v", number, err)
+		}
+		return
+	}
+
+	for _, user := range users {
+		if err := s.github.RequestReviewers(ctx, cfg.RepoOwner, cfg.RepoName, number, []string{user}, nil); err != nil {
+			result.warn("failed to request a review of #%d from %s: %v", number, user, err)
+		}
+	}
+	for _, team := range teams {
+		if err := s.github.RequestReviewers(ctx, cfg.RepoOwner, cfg.RepoName, number, nil, []string{team}); err != nil {
+			result.warn("failed to request a review of #%d from team %s: %v", number, team, err)
+		}
+	}
+}
+
+// approvers returns the users whose last review approved the change, in the
+// order of their first review. Comments do not withdraw an approval.
+func approvers(reviews []*github.PullRequestReview) []string {
+	var users []string
+	states := map[string]string{}
+	for _, review := range reviews {
+		login, state := review.GetUser().GetLogin(), review.GetState()
+		if login == "" || isBot(review.GetUser()) || state == "COMMENTED" || state == "PENDING" {
+			continue
+		}
+		if _, ok := states[login]; !ok {
+			users = append(users, login)
+		}
+		states[login] = state
+	}
+	return slices.DeleteFunc(users, func(user string) bool {
+		return states[user] != "APPROVED"
+	})
+}
+
+// isBot reports whether user is an app, which cannot be asked for reviews
+func isBot(user *github.User) bool {
+	return user.GetType() == "Bot" || strings.HasSuffix(user.GetLogin(), "[bot]")
+}
+
+// branchReviewers returns the reviewers of the cherry-picks to targetBranch:
+// the ones of all the patterns matching it, in the order of the patterns
+func (cfg *Config) branchReviewers(targetBranch string) []string {
+	var patterns []string
+	for pattern := range cfg.BranchReviewers {
+		if ok, _ := path.Match(pattern, targetBranch); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	slices.Sort(patterns)
+
+	var reviewers []string
+	for _, pattern := range patterns {
+		reviewers = append(reviewers, cfg.BranchReviewers[pattern]...)
+	}
+	return reviewers
+}
+
+// validateReviewers checks the branch patterns and reviewers of the
+// configuration. Reviewers are user logins, or org/team for teams.
+func validateReviewers(reviewers map[string][]string) error {
+	for pattern, names := range reviewers {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			return fmt.Errorf("invalid reviewer branch pattern %q", pattern)
+		}
+		for _, name := range names {
+			if !validReviewer(name) {
+				return fmt.Errorf("invalid reviewer %q for %s: expected a login or org/team", name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// validReviewer reports whether name is a login, or an org/team
+func validReviewer(name string) bool {
+	parts := strings.Split(name, "/")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.IndexFunc(part, func(r rune) bool {
+			return !(r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}) >= 0 {
+			return false
+		}
+	}
+	return true
+}
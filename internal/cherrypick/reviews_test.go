@@ -0,0 +1,232 @@
+package cherrypick
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func review(login, state string) *github.PullRequestReview {
+	return &github.PullRequestReview{User: &github.User{Login: stringPtr(login)}, State: stringPtr(state)}
+}
+
+func TestApprovers(t *testing.T) {
+	reviews := []*github.PullRequestReview{
+		review("alice", "CHANGES_REQUESTED"),
+		review("bob", "APPROVED"),
+		review("alice", "APPROVED"),
+		review("carol", "APPROVED"),
+		review("bob", "COMMENTED"),
+		review("carol", "CHANGES_REQUESTED"),
+		review("dave", "COMMENTED"),
+		review("erin", "DISMISSED"),
+		review("renovate[bot]", "APPROVED"),
+	}
+
+	if got, want := approvers(reviews), []string{"alice", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("approvers() = %v, want %v", got, want)
+	}
+}
+
+func TestBranchReviewers(t *testing.T) {
+	cfg := &Config{
+		BranchReviewers: map[string][]string{
+			"release-v1.*":    {"org/release-team"},
+			"release-v1.2.x":  {"alice"},
+			"release-v2.*":    {"bob"},
+			"release-v[12].*": {"carol"},
+		},
+	}
+
+	tests := []struct {
+		branch string
+		want   []string
+	}{
+		{"release-v1.2.x", []string{"org/release-team", "alice", "carol"}},
+		{"release-v2.0.x", []string{"bob", "carol"}},
+		{"main", nil},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.branchReviewers(tt.branch); !slices.Equal(got, tt.want) {
+			t.Errorf("branchReviewers(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestValidReviewer(t *testing.T) {
+	for _, name := range []string{"alice", "Alice-2", "org/release-team", "org/team_v1.x"} {
+		if !validReviewer(name) {
+			t.Errorf("Expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"", "@alice", "org/", "/team", "org/sub/team", "alice bob"} {
+		if validReviewer(name) {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestProcessBranch_RequestReviews(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	var users, teams [][]string
+	mockGH := metadataGitHubClient(t, &labels, &assignees, &milestone)
+	mockGH.listReviews = func(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+		if number != 123 {
+			t.Errorf("Expected the reviews of #123, got #%d", number)
+		}
+		return []*github.PullRequestReview{review("bob", "APPROVED"), review("Author", "APPROVED"), review("carol", "COMMENTED")}, nil
+	}
+	mockGH.requestReviews = func(ctx context.Context, owner, repo string, number int, r, tm []string) error {
+		if number != 789 {
+			t.Errorf("Expected reviews requested on #789, got #%d", number)
+		}
+		users, teams = append(users, r), append(teams, tm)
+		return nil
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:        123,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		RequestReviews:  true,
+		BranchReviewers: map[string][]string{"release-*": {"org/release-team", "bob", "dave"}},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+	}
+	if len(result.Warnings) > 0 {
+		t.Errorf("Expected no warnings, got %v", result.Warnings)
+	}
+
+	// The reviewers of the branch are requested separately, but the ones
+	// already requested
+	if want := [][]string{{"author", "bob"}, {"dave"}}; !slices.EqualFunc(users, want, slices.Equal) {
+		t.Errorf("Expected reviews from %v, got %v", want, users)
+	}
+	if want := [][]string{nil, {"release-team"}}; !slices.EqualFunc(teams, want, slices.Equal) {
+		t.Errorf("Expected reviews from teams %v, got %v", want, teams)
+	}
+}
+
+func TestProcessBranch_RequestReviewsFailures(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	var users []string
+	mockGH := metadataGitHubClient(t, &labels, &assignees, &milestone)
+	mockGH.listReviews = func(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+		return nil, errors.New("rate limited")
+	}
+	mockGH.requestReviews = func(ctx context.Context, owner, repo string, number int, r, tm []string) error {
+		users = r
+		return errors.New("reviewers must be collaborators")
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:       123,
+		RepoOwner:      "owner",
+		RepoName:       "repo",
+		GitUserName:    "Test Bot",
+		GitUserEmail:   "bot@test.com",
+		RequestReviews: true,
+	}
+
+	// The PR is opened all the same, with a review request from the author
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success || result.NewPR.GetNumber() != 789 {
+		t.Fatalf("Expected PR #789 to be created, got %+v (%s)", result.NewPR, result.ErrorMessage)
+	}
+	if !slices.Equal(users, []string{"author"}) {
+		t.Errorf("Expected a review request from the author, got %v", users)
+	}
+
+	if len(result.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", result.Warnings)
+	}
+	for i, want := range []string{"failed to list the reviews of #123", "failed to request reviews of #789"} {
+		if !strings.HasPrefix(result.Warnings[i], want) {
+			t.Errorf("Warning %d: expected %q, got %q", i, want, result.Warnings[i])
+		}
+	}
+}
+
+func TestProcessBranch_RequestReviewsUnprocessable(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	var requested []string
+	mockGH := metadataGitHubClient(t, &labels, &assignees, &milestone)
+	mockGH.requestReviews = func(ctx context.Context, owner, repo string, number int, r, tm []string) error {
+		reviewers := append(slices.Clone(r), tm...)
+		if slices.Contains(reviewers, "gone") {
+			return &github.ErrorResponse{
+				Response: &http.Response{StatusCode: http.StatusUnprocessableEntity},
+				Message:  "Reviews may only be requested from collaborators",
+			}
+		}
+		requested = append(requested, reviewers...)
+		return nil
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:        123,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		RequestReviews:  true,
+		BranchReviewers: map[string][]string{"release-*": {"bob", "gone", "org/release-team"}},
+	}
+
+	// The reviewers GitHub accepts are requested one by one
+	result := service.ProcessBranch(context.Background(), cfg, "release-1.0")
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+	}
+	if want := []string{"author", "bob", "release-team"}; !slices.Equal(requested, want) {
+		t.Errorf("Expected reviews from %v, got %v", want, requested)
+	}
+	if len(result.Warnings) != 1 || !strings.HasPrefix(result.Warnings[0], "failed to request a review of #789 from gone") {
+		t.Errorf("Expected a warning for gone, got %v", result.Warnings)
+	}
+}
+
+func TestProcessBranch_NoReviews(t *testing.T) {
+	var labels, assignees []string
+	var milestone string
+	mockGH := metadataGitHubClient(t, &labels, &assignees, &milestone)
+	mockGH.listReviews = func(ctx context.Context, owner, repo string, number int) ([]*github.PullRequestReview, error) {
+		t.Error("Unexpected listing of the reviews")
+		return nil, nil
+	}
+	mockGH.requestReviews = func(ctx context.Context, owner, repo string, number int, r, tm []string) error {
+		t.Errorf("Unexpected review request from %v and %v", r, tm)
+		return nil
+	}
+	service := NewService(mockGH, &mockGitRunner{})
+
+	cfg := &Config{
+		PRNumber:        123,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		BranchReviewers: map[string][]string{"release-2.*": {"bob"}},
+	}
+
+	if result := service.ProcessBranch(context.Background(), cfg, "release-1.0"); !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.ErrorMessage)
+	}
+}
@@ -0,0 +1,179 @@
+package cherrypick
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SigningFormat is the kind of key the picked commits are signed with. The
+// values are the ones of git's gpg.format.
+type SigningFormat string
+
+const (
+	SigningFormatSSH SigningFormat = "ssh"
+	SigningFormatGPG SigningFormat = "openpgp"
+)
+
+// Signer signs the picked commits with a private key. The key material is
+// kept in a temporary directory until Close.
+type Signer struct {
+	format SigningFormat
+	dir    string
+	// signingKey is git's user.signingKey: the key file for SSH, the
+	// fingerprint of the key for GPG
+	signingKey string
+	// env is the environment of the signing program
+	env []string
+}
+
+// NewSigner loads an unencrypted private key and checks that it can sign
+// the commits of committer email. GPG keys must carry a user ID for email;
+// SSH keys have no identity and are verified by GitHub against the signing
+// keys of the committer's account.
+func NewSigner(ctx context.Context, format SigningFormat, key []byte, email string) (*Signer, error) {
+	dir, err := os.MkdirTemp("", "cherry-pick-signing-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signing directory: %w", err)
+	}
+
+	signer := &Signer{format: format, dir: dir}
+	switch format {
+	case SigningFormatSSH:
+		err = signer.loadSSHKey(ctx, key)
+	case SigningFormatGPG:
+		err = signer.loadGPGKey(ctx, key, email)
+	default:
+		err = fmt.Errorf("unknown signing format %q: expected %s or %s", format, SigningFormatSSH, SigningFormatGPG)
+	}
+	if err != nil {
+		signer.Close()
+		return nil, err
+	}
+	return signer, nil
+}
+
+// Format returns the kind of key of the signer
+func (s *Signer) Format() SigningFormat {
+	return s.format
+}
+
+// Close removes the key material, stopping the GPG agent if one was started
+func (s *Signer) Close() error {
+	if s.format == SigningFormatGPG {
+		_, _ = s.run(context.Background(), nil, "gpgconf", "--kill", "gpg-agent")
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// loadSSHKey writes the key where ssh-keygen accepts it and signs a test
+// message with it
+func (s *Signer) loadSSHKey(ctx context.Context, key []byte) error {
+	if !bytes.HasSuffix(key, []byte("\n")) {
+		key = append(key, '\n')
+	}
+	s.signingKey = filepath.Join(s.dir, "key")
+	if err := os.WriteFile(s.signingKey, key, 0o600); err != nil {
+		return fmt.Errorf("failed to write SSH key: %w", err)
+	}
+
+	if _, err := s.run(ctx, nil, "ssh-keygen", "-y", "-P", "", "-f", s.signingKey); err != nil {
+		return fmt.Errorf("invalid SSH key (it must not be passphrase-protected): %w", err)
+	}
+	if _, err := s.run(ctx, strings.NewReader("test"), "ssh-keygen", "-Y", "sign", "-n", "git", "-f", s.signingKey); err != nil {
+		return fmt.Errorf("SSH key cannot sign: %w", err)
+	}
+	return nil
+}
+
+// loadGPGKey imports the key in a keyring of its own, picks the secret key
+// carrying a user ID for email and signs a test message with it
+func (s *Signer) loadGPGKey(ctx context.Context, key []byte, email string) error {
+	home := filepath.Join(s.dir, "gnupg")
+	if err := os.Mkdir(home, 0o700); err != nil {
+		return fmt.Errorf("failed to create GPG home: %w", err)
+	}
+	s.env = []string{"GNUPGHOME=" + home}
+
+	if _, err := s.run(ctx, bytes.NewReader(key), "gpg", "--batch", "--import"); err != nil {
+		return fmt.Errorf("invalid GPG key: %w", err)
+	}
+
+	keys, err := s.run(ctx, nil, "gpg", "--batch", "--with-colons", "--list-secret-keys")
+	if err != nil {
+		return fmt.Errorf("failed to list GPG keys: %w", err)
+	}
+	s.signingKey = gpgSigningKey(keys, email)
+	if s.signingKey == "" {
+		return fmt.Errorf("no usable GPG signing key with a user ID for %s", email)
+	}
+
+	if _, err := s.run(ctx, strings.NewReader("test"), "gpg", "--batch", "--pinentry-mode", "error", "--local-user", s.signingKey, "--detach-sign"); err != nil {
+		return fmt.Errorf("GPG key cannot sign (it must not be passphrase-protected): %w", err)
+	}
+	return nil
+}
+
+// gpgSigningKey returns the fingerprint of the first valid signing key of
+// a `gpg --with-colons --list-secret-keys` listing with a user ID for email
+func gpgSigningKey(listing, email string) string {
+	var fingerprint string
+	var usable, primary bool
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Split(line, ":")
+		switch fields[0] {
+		case "sec":
+			// Expired, revoked and disabled keys cannot sign, and the
+			// key capabilities are upper-cased for the whole key
+			usable = len(fields) > 11 && !strings.ContainsAny(fields[1], "erd") && strings.Contains(fields[11], "S")
+			fingerprint, primary = "", true
+		case "ssb":
+			primary = false
+		case "fpr":
+			if primary && fingerprint == "" && len(fields) > 9 {
+				fingerprint = fields[9]
+			}
+		case "uid":
+			if usable && len(fields) > 9 && !strings.ContainsAny(fields[1], "er") &&
+				strings.Contains(strings.ToLower(fields[9]), "<"+strings.ToLower(email)+">") {
+				return fingerprint
+			}
+		}
+	}
+	return ""
+}
+
+// gitConfig returns the git options signing the created commits
+func (s *Signer) gitConfig() []string {
+	return []string{
+		"-c", "commit.gpgSign=true",
+		"-c", "gpg.format=" + string(s.format),
+		"-c", "user.signingKey=" + s.signingKey,
+	}
+}
+
+// run runs a signing program with stdin and returns its standard output
+func (s *Signer) run(ctx context.Context, stdin io.Reader, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Env = append(os.Environ(), s.env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// WithSigner makes the service sign the commits it creates with signer
+func WithSigner(signer *Signer) Option {
+	return func(s *Service) {
+		s.signer = signer
+	}
+}
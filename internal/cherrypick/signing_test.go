@@ -0,0 +1,151 @@
+package cherrypick
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGPGSigningKey(t *testing.T) {
+	listing := strings.Join([]string{
+		"sec:e:255:22:1111111111111111:1600000000:1650000000::u:::scSC:::+:::ed25519:::0:",
+		"fpr:::::::::EXPIRED0000000000000000000000001111111111111111:",
+		"uid:e::::1600000000::AAAA::Test Bot <bot@test.com>::::::::::0:",
+		"sec:u:255:22:2222222222222222:1600000000:::u:::scSC:::+:::ed25519:::0:",
+		"fpr:::::::::VALID00000000000000000000000002222222222222222:",
+		"uid:u::::1600000000::BBBB::Someone Else <else@test.com>::::::::::0:",
+		"uid:u::::1600000000::CCCC::Test Bot <Bot@Test.com>::::::::::0:",
+		"ssb:u:255:18:3333333333333333:1600000000::::::e:::+:::cv25519::",
+		"fpr:::::::::SUBKEY0000000000000000000000003333333333333333:",
+	}, "\n")
+
+	if got := gpgSigningKey(listing, "bot@test.com"); got != "VALID00000000000000000000000002222222222222222" {
+		t.Errorf("Expected the valid key, got %q", got)
+	}
+	if got := gpgSigningKey(listing, "nobody@test.com"); got != "" {
+		t.Errorf("Expected no key for an unknown email, got %q", got)
+	}
+}
+
+func TestNewSigner_Invalid(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	if _, err := NewSigner(context.Background(), "x509", []byte("key"), "bot@test.com"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := NewSigner(context.Background(), SigningFormatSSH, []byte("not a key"), "bot@test.com"); err == nil {
+		t.Error("Expected an error for an invalid SSH key")
+	}
+}
+
+// signedPick cherry-picks PR #1 to release-v1.0 signing with signer, and
+// returns the repositories and the picked branch
+func signedPick(t *testing.T, signer *Signer) (*realRepos, string) {
+	t.Helper()
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodRebase)
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone}, WithSigner(signer))
+	cfg := &Config{
+		PRNumber:     1,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		Trailers:     []string{"Backport-Of: #1"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Cherry-pick failed: %s", result.ErrorMessage)
+	}
+	return repos, "cherry-pick-1-to-release-v1.0"
+}
+
+func TestSigner_SSHRealGit(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	// Throwaway key, passed without trailing newline as from an
+	// environment variable
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "bot@test.com", "-f", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v: %s", err, output)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewSigner(context.Background(), SigningFormatSSH, []byte(strings.TrimSpace(string(key))), "bot@test.com")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	defer signer.Close()
+
+	repos, branch := signedPick(t, signer)
+
+	publicKey, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedSigners := filepath.Join(t.TempDir(), "allowed_signers")
+	writeFile(t, allowedSigners, "bot@test.com "+string(publicKey))
+
+	for _, commit := range []string{branch, branch + "^"} {
+		gitRepo(t, repos.origin, "-c", "gpg.ssh.allowedSignersFile="+allowedSigners, "verify-commit", commit)
+	}
+}
+
+func TestSigner_GPGRealGit(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+
+	// Throwaway key in a keyring of its own, which also verifies the
+	// signatures
+	home, err := os.MkdirTemp("", "gnupg-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GNUPGHOME", home)
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+
+	gpg := func(args ...string) []byte {
+		t.Helper()
+		output, err := exec.Command("gpg", append([]string{"--batch", "--pinentry-mode", "loopback", "--passphrase", ""}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("gpg %s: %v", strings.Join(args, " "), err)
+		}
+		return output
+	}
+	gpg("--quick-generate-key", "Other <other@test.com>", "ed25519", "sign", "never")
+	other := gpg("--armor", "--export-secret-keys", "other@test.com")
+	gpg("--quick-generate-key", "Test Bot <bot@test.com>", "ed25519", "sign", "never")
+	key := gpg("--armor", "--export-secret-keys", "bot@test.com")
+
+	// The key must belong to the committer
+	if _, err := NewSigner(context.Background(), SigningFormatGPG, other, "bot@test.com"); err == nil {
+		t.Error("Expected an error for a key of another identity")
+	}
+
+	signer, err := NewSigner(context.Background(), SigningFormatGPG, key, "bot@test.com")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	defer signer.Close()
+
+	repos, branch := signedPick(t, signer)
+
+	for _, commit := range []string{branch, branch + "^"} {
+		gitRepo(t, repos.origin, "verify-commit", commit)
+	}
+}
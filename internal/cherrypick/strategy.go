@@ -0,0 +1,82 @@
+package cherrypick
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// strategyOptionNames are the merge strategy options (git cherry-pick -X)
+// that can be requested, without their =<value> part
+var strategyOptionNames = []string{
+	"ours",
+	"theirs",
+	"ignore-space-change",
+	"ignore-all-space",
+	"ignore-space-at-eol",
+	"ignore-cr-at-eol",
+	"renormalize",
+	"no-renormalize",
+	"find-renames",
+	"rename-threshold",
+	"no-renames",
+	"subtree",
+	"patience",
+	"diff-algorithm",
+}
+
+// validateStrategyOption checks that option is a known merge strategy option
+func validateStrategyOption(option string) error {
+	name, _, _ := strings.Cut(option, "=")
+	for _, known := range strategyOptionNames {
+		if name == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid strategy option %q: expected one of %s", option, strings.Join(strategyOptionNames, ", "))
+}
+
+// strategyOptions returns the -X options of the pick to targetBranch: the
+// ones of the request, or else the ones configured for the branch
+func (cfg *Config) strategyOptions(targetBranch string) []string {
+	if len(cfg.StrategyOptions) > 0 {
+		return cfg.StrategyOptions
+	}
+	return cfg.BranchStrategyOptions[targetBranch]
+}
+
+// useRerereCache points the rr-cache of the repository, which all worktrees
+// share, at dir so that the resolutions recorded there are replayed
+func (s *Service) useRerereCache(ctx context.Context, dir string) error {
+	s.repoMu.Lock()
+	defer s.repoMu.Unlock()
+
+	cache, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid rerere cache %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(cache, 0o755); err != nil {
+		return fmt.Errorf("failed to create rerere cache: %w", err)
+	}
+
+	commonDir, err := s.runGit(ctx, "", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return fmt.Errorf("failed to locate the repository: %w", err)
+	}
+
+	rrCache := filepath.Join(commonDir, "rr-cache")
+	if target, err := os.Readlink(rrCache); err == nil && target == cache {
+		return nil
+	}
+	// Only an earlier link or an empty directory is replaced, never
+	// resolutions recorded in the repository itself
+	if err := os.Remove(rrCache); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s with the rerere cache: %w", rrCache, err)
+	}
+	if err := os.Symlink(cache, rrCache); err != nil {
+		return fmt.Errorf("failed to link the rerere cache: %w", err)
+	}
+	return nil
+}
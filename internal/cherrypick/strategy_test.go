@@ -0,0 +1,159 @@
+package cherrypick
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestProcessBranch_StrategyOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		branch string
+		want   []string
+	}{
+		{
+			name:   "none",
+			branch: "release-1.0",
+		},
+		{
+			name:   "request",
+			cfg:    Config{StrategyOptions: []string{"theirs", "ignore-space-change"}},
+			branch: "release-1.0",
+			want:   []string{"-X", "theirs", "-X", "ignore-space-change"},
+		},
+		{
+			name:   "branch",
+			cfg:    Config{BranchStrategyOptions: map[string][]string{"release-1.0": {"ours"}}},
+			branch: "release-1.0",
+			want:   []string{"-X", "ours"},
+		},
+		{
+			name:   "other branch",
+			cfg:    Config{BranchStrategyOptions: map[string][]string{"release-2.0": {"ours"}}},
+			branch: "release-1.0",
+		},
+		{
+			name: "request overrides branch",
+			cfg: Config{
+				StrategyOptions:       []string{"theirs"},
+				BranchStrategyOptions: map[string][]string{"release-1.0": {"ours"}},
+			},
+			branch: "release-1.0",
+			want:   []string{"-X", "theirs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGit := &mockGitRunner{}
+			mockGH := &mockGitHubClient{
+				getPR: func(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+					return &github.PullRequest{Merged: boolPtr(true), MergeCommitSHA: stringPtr("abc123")}, nil
+				},
+				createPR: func(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+					return &github.PullRequest{Number: intPtr(456)}, nil
+				},
+			}
+			service := NewService(mockGH, mockGit)
+
+			cfg := tt.cfg
+			cfg.PRNumber = 123
+			cfg.RepoOwner = "owner"
+			cfg.RepoName = "repo"
+
+			result := service.ProcessBranch(context.Background(), &cfg, tt.branch)
+			if !result.Success {
+				t.Fatalf("Expected success, got %s", result.ErrorMessage)
+			}
+
+			var got []string
+			for _, cmd := range mockGit.commands {
+				args := gitSubcommand(cmd)
+				if args[0] != "cherry-pick" {
+					continue
+				}
+				for i := 0; i < len(args); i++ {
+					if args[i] == "-X" {
+						got = append(got, args[i], args[i+1])
+					}
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected strategy options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProcessBranch_StrategyOptionRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodMerge)
+	addConflicts(t, repos, "release-v1.0", "fix.txt")
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:        1,
+		RepoOwner:       "owner",
+		RepoName:        "repo",
+		GitUserName:     "Test Bot",
+		GitUserEmail:    "bot@test.com",
+		StrategyOptions: []string{"theirs"},
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Expected the conflict to be resolved, got %s", result.ErrorMessage)
+	}
+
+	if fix := gitRepo(t, repos.origin, "show", "cherry-pick-1-to-release-v1.0:fix.txt"); fix != "fix" {
+		t.Errorf("Expected the picked side of fix.txt, got %q", fix)
+	}
+}
+
+func TestProcessBranch_RerereCacheRealGit(t *testing.T) {
+	repos := setupRealRepos(t, []string{"release-v1.0"}, MergeMethodMerge)
+	addConflicts(t, repos, "release-v1.0", "fix.txt")
+
+	// Record a resolution of the conflict in another clone
+	recorder := filepath.Join(t.TempDir(), "recorder")
+	gitRepo(t, repos.clone, "clone", repos.origin, recorder)
+	gitRepo(t, recorder, "config", "user.name", "Author")
+	gitRepo(t, recorder, "config", "user.email", "author@test.com")
+	gitRepo(t, recorder, "config", "rerere.enabled", "true")
+	gitRepo(t, recorder, "checkout", "release-v1.0")
+	if err := exec.Command("git", "-C", recorder, "cherry-pick", "-m", "1", repos.mergeCommit).Run(); err == nil {
+		t.Fatal("Expected the recorded cherry-pick to conflict")
+	}
+	writeFile(t, filepath.Join(recorder, "fix.txt"), "resolved\n")
+	gitRepo(t, recorder, "rerere")
+	gitRepo(t, recorder, "add", "fix.txt")
+	gitRepo(t, recorder, "-c", "core.editor=true", "cherry-pick", "--continue")
+
+	service := NewService(newRealGitHubClient(t, repos), &CommandGitRunner{Dir: repos.clone})
+	cfg := &Config{
+		PRNumber:     1,
+		RepoOwner:    "owner",
+		RepoName:     "repo",
+		GitUserName:  "Test Bot",
+		GitUserEmail: "bot@test.com",
+		RerereCache:  filepath.Join(recorder, ".git", "rr-cache"),
+	}
+
+	result := service.ProcessBranch(context.Background(), cfg, "release-v1.0")
+	if !result.Success {
+		t.Fatalf("Expected the recorded resolution to be replayed, got %s", result.ErrorMessage)
+	}
+
+	branch := "cherry-pick-1-to-release-v1.0"
+	if fix := gitRepo(t, repos.origin, "show", branch+":fix.txt"); fix != "resolved" {
+		t.Errorf("Expected the recorded resolution of fix.txt, got %q", fix)
+	}
+	if subject := gitRepo(t, repos.origin, "log", "-1", "--format=%s", branch); subject != "Merge pull request #1" {
+		t.Errorf("Expected the picked commit message, got %q", subject)
+	}
+}
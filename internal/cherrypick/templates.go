@@ -0,0 +1,142 @@
+package cherrypick
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// Default templates of the cherry-picks of a PR
+const (
+	DefaultBranchTemplate = "cherry-pick-{{.Number}}-to-{{.Target}}"
+	DefaultTitleTemplate  = "Cherry-pick #{{.Number}} to {{.Target}}"
+	DefaultBodyTemplate   = "Automatic cherry-pick of #{{.Number}} to `{{.Target}}`" +
+		"{{if .Title}}\n\n### [{{.Title}}]({{.URL}}){{end}}" +
+		"{{with .Author}}\n\nOriginally authored by @{{.}}.{{end}}" +
+		"{{with .Body}}\n\n{{quote .}}{{end}}\n"
+)
+
+// maxBodyLength is the longest PR body GitHub accepts, in characters
+const maxBodyLength = 65536
+
+// TemplateData is what the branch name, PR title and PR body templates are
+// executed with
+type TemplateData struct {
+	// Number, Title, Body, Author, Labels and URL describe the original PR
+	Number int
+	Title  string
+	Body   string
+	Author string
+	Labels []string
+	URL    string
+	// Target is the target branch
+	Target string
+}
+
+// templateFuncs are the functions available to the templates besides the
+// text/template builtins
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"quote": quote,
+	"slug":  slug,
+}
+
+// newTemplateData returns the data of the templates for the pick of pr, the
+// PR of cfg, to targetBranch
+func newTemplateData(cfg *Config, pr *github.PullRequest, targetBranch string) *TemplateData {
+	data := &TemplateData{
+		Number: cfg.PRNumber,
+		Title:  pr.GetTitle(),
+		Body:   strings.TrimSpace(strings.ReplaceAll(pr.GetBody(), "\r\n", "\n")),
+		Author: pr.GetUser().GetLogin(),
+		URL:    pr.GetHTMLURL(),
+		Target: targetBranch,
+	}
+	if pr != nil {
+		for _, label := range pr.Labels {
+			data.Labels = append(data.Labels, label.GetName())
+		}
+	}
+	return data
+}
+
+// parseTemplate parses one of the templates of the configuration, named after
+// its option
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// executeTemplate renders text, or fallback when it is empty, with data
+func executeTemplate(name, text, fallback string, data *TemplateData) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render the %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// validateTemplates checks that the branch name, title and body templates
+// parse
+func validateTemplates(branch, title, body string) error {
+	for _, tmpl := range []struct{ name, text string }{{"branch", branch}, {"title", title}, {"body", body}} {
+		if _, err := parseTemplate(tmpl.name, tmpl.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidRefChars matches what git does not allow in branch names
+var invalidRefChars = regexp.MustCompile(`[\x00-\x20\x7f~^:?*\[\\]|\.\.|@\{|//`)
+
+// validateBranchName checks that a rendered branch name is a valid git branch
+// name
+func validateBranchName(name string) error {
+	if name == "" || invalidRefChars.MatchString(name) || strings.HasPrefix(name, "-") ||
+		strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	return nil
+}
+
+// truncateBody cuts a PR body to what GitHub accepts
+func truncateBody(body string) string {
+	const notice = "\n\n_(truncated)_\n"
+	if len([]rune(body)) <= maxBodyLength {
+		return body
+	}
+	return string([]rune(body)[:maxBodyLength-len(notice)]) + notice
+}
+
+// quote turns text into a markdown blockquote
+func quote(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug turns text into lower-case words separated by dashes, for branch
+// names
+func slug(text string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
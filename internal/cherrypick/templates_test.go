@@ -0,0 +1,111 @@
+package cherrypick
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func templatePR() *github.PullRequest {
+	return &github.PullRequest{
+		Number:  intPtr(42),
+		Title:   stringPtr("Fix the flaky retry"),
+		Body:    stringPtr("Retries now back off.\r\n\r\n- [x] Tests\r\n"),
+		User:    &github.User{Login: stringPtr("alice")},
+		HTMLURL: stringPtr("https://github.com/owner/repo/pull/42"),
+		Labels:  []*github.Label{{Name: stringPtr("kind/bug")}, {Name: stringPtr("area/api")}},
+	}
+}
+
+func TestDescribe_DefaultTemplates(t *testing.T) {
+	plan := &pickPlan{pr: templatePR(), commits: []string{"abc123"}}
+	cfg := &Config{PRNumber: 42, RepoOwner: "owner", RepoName: "repo"}
+
+	branch, err := plan.branchName(cfg, "release-v1.0")
+	if err != nil || branch != "cherry-pick-42-to-release-v1.0" {
+		t.Errorf("branchName() = %q, %v", branch, err)
+	}
+
+	title, body, err := plan.describe(cfg, "release-v1.0")
+	if err != nil {
+		t.Fatalf("describe() error = %v", err)
+	}
+	if title != "Cherry-pick #42 to release-v1.0" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	want := "Automatic cherry-pick of #42 to `release-v1.0`\n\n" +
+		"### [Fix the flaky retry](https://github.com/owner/repo/pull/42)\n\n" +
+		"Originally authored by @alice.\n\n" +
+		"> Retries now back off.\n>\n> - [x] Tests\n"
+	if body != want {
+		t.Errorf("Unexpected body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestDescribe_CustomTemplates(t *testing.T) {
+	plan := &pickPlan{pr: templatePR(), commits: []string{"abc123"}}
+	cfg := &Config{
+		PRNumber:       42,
+		BranchTemplate: "backport/{{.Target}}/{{.Number}}-{{slug .Title}}",
+		TitleTemplate:  "[{{.Target}}] {{.Title}}\n",
+		BodyTemplate:   "Backport of {{.URL}} ({{join .Labels \", \"}})",
+	}
+
+	branch, err := plan.branchName(cfg, "release-v1.0")
+	if err != nil || branch != "backport/release-v1.0/42-fix-the-flaky-retry" {
+		t.Errorf("branchName() = %q, %v", branch, err)
+	}
+	title, body, err := plan.describe(cfg, "release-v1.0")
+	if err != nil {
+		t.Fatalf("describe() error = %v", err)
+	}
+	if title != "[release-v1.0] Fix the flaky retry" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	if body != "Backport of https://github.com/owner/repo/pull/42 (kind/bug, area/api)" {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
+
+func TestDescribe_TemplateErrors(t *testing.T) {
+	plan := &pickPlan{pr: templatePR(), commits: []string{"abc123"}}
+
+	if _, err := plan.branchName(&Config{PRNumber: 42, BranchTemplate: "{{.Title}}"}, "main"); err == nil || !strings.Contains(err.Error(), "invalid branch name") {
+		t.Errorf("Expected an invalid branch name, got %v", err)
+	}
+	if _, _, err := plan.describe(&Config{PRNumber: 42, TitleTemplate: "{{.Milestone}}"}, "main"); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+	if _, _, err := plan.describe(&Config{PRNumber: 42, TitleTemplate: "{{if false}}x{{end}}"}, "main"); err == nil {
+		t.Error("Expected an error for an empty title")
+	}
+
+	cfg := &Config{PRNumber: 42, Branches: []string{"main"}, RepoOwner: "owner", RepoName: "repo", BodyTemplate: "{{.Body"}
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "invalid body template") {
+		t.Errorf("Expected an invalid body template, got %v", err)
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	for _, name := range []string{"cherry-pick-1-to-main", "backport/release-v1.0/1", "a.b"} {
+		if err := validateBranchName(name); err != nil {
+			t.Errorf("validateBranchName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "with space", "a..b", "a:b", "-a", "a/", "a.lock", "a//b", "a@{b", "a."} {
+		if err := validateBranchName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	if body := truncateBody("short"); body != "short" {
+		t.Errorf("Expected a short body to be kept, got %q", body)
+	}
+	body := truncateBody(strings.Repeat("é", maxBodyLength+10))
+	if n := len([]rune(body)); n != maxBodyLength || !strings.HasSuffix(body, "_(truncated)_\n") {
+		t.Errorf("Expected a truncated body of %d characters, got %d", maxBodyLength, n)
+	}
+}